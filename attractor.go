@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// the distance from an attractor at which its pull has dropped to half
+const attractorFalloff = 100.0
+
+// Attractor is a fixed point goids steer towards, or away from if its
+// strength is negative
+type Attractor struct {
+	Pos      Vec2    `json:"pos"`
+	Strength float64 `json:"strength"`
+}
+
+// steer towards (or away from) each attractor, weaker the further away it is
+func attract(g *Goid, attractors []Attractor) (steer Vec2) {
+	for _, a := range attractors {
+		d := a.Pos.Sub(g.Pos)
+		falloff := attractorFalloff / (attractorFalloff + d.Len())
+		steer = steer.Add(d.Normalize().Scale(a.Strength * falloff))
+	}
+	return
+}
+
+// read a JSON array of attractors from a file, like
+// [{"pos": {"x": 200, "y": 300}, "strength": 2}, {"pos": {"x": 600, "y": 300}, "strength": -2}]
+func loadAttractors(path string) (attractors []Attractor, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &attractors); err != nil {
+		err = fmt.Errorf("reading attractors from %s: %v", path, err)
+	}
+	return
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+)
+
+// blockRenderer draws frames with 24-bit colored upper half blocks, so each
+// character cell shows two vertically stacked pixels: the top one as the
+// foreground color and the bottom one as the background
+type blockRenderer struct {
+	background color.RGBA // what the frame's drawn on, which shows where nothing is
+}
+
+func (br blockRenderer) printImage(w io.Writer, img image.Image) error {
+	cols, rows := terminalSize()
+	rows -= 2 // leave room for the status line
+	if cols < 1 || rows < 1 {
+		return nil
+	}
+	pixels := downsample(img, cols, rows*2, br.background)
+
+	var sb strings.Builder
+	sb.WriteString("\x1b[2;0H")
+	for row := 0; row < rows; row++ {
+		var fg, bg color.RGBA
+		for col := 0; col < cols; col++ {
+			top, bottom := pixels[row*2*cols+col], pixels[(row*2+1)*cols+col]
+			if col == 0 || top != fg {
+				fmt.Fprintf(&sb, "\x1b[38;2;%d;%d;%dm", top.R, top.G, top.B)
+				fg = top
+			}
+			if col == 0 || bottom != bg {
+				fmt.Fprintf(&sb, "\x1b[48;2;%d;%d;%dm", bottom.R, bottom.G, bottom.B)
+				bg = bottom
+			}
+			sb.WriteRune('▀')
+		}
+		sb.WriteString("\x1b[0m\r\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// scale an image down to w x h, keeping the pixel of each block that stands
+// out most from the background so small goids don't get averaged away into
+// it, whether it's darker or lighter than they are
+func downsample(img image.Image, w, h int, background color.RGBA) []color.RGBA {
+	out := make([]color.RGBA, w*h)
+	for i := range out {
+		out[i] = color.RGBA{background.R, background.G, background.B, 255}
+	}
+	best := make([]uint32, w*h)
+	br, bg, bb, _ := background.RGBA()
+	bounds := img.Bounds()
+	iw, ih := bounds.Dx(), bounds.Dy()
+	for y := 0; y < ih; y++ {
+		for x := 0; x < iw; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			if v := diff(r, br) + diff(g, bg) + diff(b, bb); v > 0 {
+				i := (y*h/ih)*w + x*w/iw
+				if v > best[i] {
+					best[i] = v
+					out[i] = color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 255}
+				}
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDownsample(t *testing.T) {
+	black := color.RGBA{0, 0, 0, 255}
+	white := color.RGBA{255, 255, 255, 255}
+	goid := color.RGBA{200, 200, 100, 255}
+	dark := color.RGBA{40, 40, 120, 255}
+	tests := []struct {
+		background color.RGBA
+		pixels     []color.RGBA // what's drawn in the block, the rest is background
+		want       color.RGBA
+	}{
+		{black, nil, black},
+		{black, []color.RGBA{goid}, goid},
+		{black, []color.RGBA{{10, 10, 10, 255}, goid}, goid},
+		{white, nil, white},
+		{white, []color.RGBA{goid}, goid},
+		{white, []color.RGBA{dark}, dark},
+		{white, []color.RGBA{{250, 250, 250, 255}, dark}, dark}, // a trail all but faded away
+		{white, []color.RGBA{goid, black}, black},
+		{color.RGBA{0, 0, 80, 255}, nil, color.RGBA{0, 0, 80, 255}},
+		{color.RGBA{0, 0, 80, 255}, []color.RGBA{dark}, dark},
+	}
+	for _, tt := range tests {
+		// a block of 4 pixels down to 1
+		img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+		for i := 0; i < 4; i++ {
+			c := tt.background
+			if i < len(tt.pixels) {
+				c = tt.pixels[i]
+			}
+			img.SetRGBA(i%2, i/2, c)
+		}
+		if got := downsample(img, 1, 1, tt.background)[0]; got != tt.want {
+			t.Errorf("%v on %v came out %v, want %v", tt.pixels, tt.background, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import "math"
+
+// BoundaryMode is what happens to goids when they reach the edge of the window
+type BoundaryMode int
+
+const (
+	Wrap   BoundaryMode = iota // leave one side and come back on the other
+	Bounce                     // bounce off the walls
+)
+
+var boundaryModes = []string{"wrap", "bounce"}
+
+func (m BoundaryMode) String() string { return enumName(boundaryModes, int(m)) }
+
+// MarshalText lets the mode be used as a flag and in JSON by name
+func (m BoundaryMode) MarshalText() ([]byte, error) { return []byte(m.String()), nil }
+
+// UnmarshalText parses the name of a mode
+func (m *BoundaryMode) UnmarshalText(text []byte) error {
+	return parseEnum(boundaryModes, text, "boundary", (*int)(m))
+}
+
+// if goid goes out of the window frame it comes back on the other side
+func stayInWindow(goid *Goid, width, height float64) {
+	goid.Pos.X = wrap(goid.Pos.X, width)
+	goid.Pos.Y = wrap(goid.Pos.Y, height)
+}
+
+// wrap v into [0, size), even if it overshoots by more than one size
+func wrap(v, size float64) float64 {
+	v = math.Mod(v, size)
+	if v < 0 {
+		v += size
+	}
+	return v
+}
+
+// the shorter of going d along a loop of the given size, or the other way
+// round it, between -size/2 and size/2
+func wrapOffset(d, size float64) float64 {
+	d = math.Mod(d, size)
+	if d > size/2 {
+		d -= size
+	} else if d < -size/2 {
+		d += size
+	}
+	return d
+}
+
+// if goid goes out of the window frame it's put back on the edge and turned
+// around, as if it bounced off the wall
+func bounceOffWalls(goid *Goid, width, height float64) {
+	goid.Pos.X, goid.Vel.X = bounce(goid.Pos.X, goid.Vel.X, width)
+	goid.Pos.Y, goid.Vel.Y = bounce(goid.Pos.Y, goid.Vel.Y, height)
+}
+
+// clamp p into [0, size], reversing v if it was heading out
+func bounce(p, v, size float64) (float64, float64) {
+	if p < 0 {
+		return 0, math.Abs(v)
+	}
+	if p > size {
+		return size, -math.Abs(v)
+	}
+	return p, v
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStayInWindow(t *testing.T) {
+	const width, height = 800, 600
+	tests := []struct {
+		in, want Vec2
+	}{
+		{Vec2{400, 300}, Vec2{400, 300}},
+		{Vec2{0, 0}, Vec2{0, 0}},
+		{Vec2{width, height}, Vec2{0, 0}},
+		{Vec2{810, 300}, Vec2{10, 300}},
+		{Vec2{-10, 300}, Vec2{790, 300}},
+		{Vec2{400, 610}, Vec2{400, 10}},
+		{Vec2{400, -10}, Vec2{400, 590}},
+		// more than a whole window past the edge in one go
+		{Vec2{3*width + 25, 300}, Vec2{25, 300}},
+		{Vec2{-3*width - 25, 300}, Vec2{775, 300}},
+		{Vec2{400, 5*height + 40}, Vec2{400, 40}},
+		{Vec2{400, -5*height - 40}, Vec2{400, 560}},
+		{Vec2{-2*width - 1, 7*height + 1}, Vec2{799, 1}},
+	}
+	for _, tt := range tests {
+		g := &Goid{Pos: tt.in}
+		stayInWindow(g, width, height)
+		if math.Abs(g.Pos.X-tt.want.X) > 1e-9 || math.Abs(g.Pos.Y-tt.want.Y) > 1e-9 {
+			t.Errorf("stayInWindow(%v) = %v, want %v", tt.in, g.Pos, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+// how many times the collision pass goes over the flock. Pushing one pair
+// apart can push one of them into another, so it takes a few goes to sort
+// out a crowd, but a tightly packed one might never be, so it gives up.
+const collisionPasses = 4
+
+// push apart any goids that overlap, so they end up just touching. Each of
+// a pair moves half the way, unless one is a leader, which keeps to its path
+// while the other moves all of it.
+func (s *Simulation) resolveCollisions() {
+	cfg := s.Config
+	maxR := 0
+	for _, g := range s.Goids {
+		maxR = max(maxR, g.R)
+	}
+	if maxR == 0 {
+		return
+	}
+	moved := make(map[*Goid]bool)
+	for pass := 0; pass < collisionPasses; pass++ {
+		gr := newGrid(s.Goids, float64(2*maxR), cfg.Width, cfg.Height, cfg.torus(), s.cells)
+		s.cells = gr.cells
+		overlaps := 0
+		for _, g := range s.Goids {
+			gr.eachWithin(g, float64(2*maxR), func(n *Goid, pos Vec2) {
+				// each pair once
+				if n.ID < g.ID || (g.Leader && n.Leader) {
+					return
+				}
+				d := Vec3{pos.X, pos.Y, n.Z}.Sub(g.pos3())
+				touch := float64(g.R + n.R)
+				dist := d.Len()
+				if dist >= touch {
+					return
+				}
+				overlaps++
+				// goids right on top of each other have no way apart, so
+				// they're split sideways
+				dir := Vec3{1, 0, 0}
+				if dist > 0 {
+					dir = d.Scale(1 / dist)
+				}
+				push := touch - dist
+				gShare, nShare := 0.5, 0.5
+				if g.Leader {
+					gShare, nShare = 0, 1
+				} else if n.Leader {
+					gShare, nShare = 1, 0
+				}
+				g.Pos.X, g.Pos.Y, g.Z = g.Pos.X-dir.X*push*gShare, g.Pos.Y-dir.Y*push*gShare, g.Z-dir.Z*push*gShare
+				n.Pos.X, n.Pos.Y, n.Z = n.Pos.X+dir.X*push*nShare, n.Pos.Y+dir.Y*push*nShare, n.Z+dir.Z*push*nShare
+				moved[g], moved[n] = true, true
+			})
+		}
+		if overlaps == 0 {
+			break
+		}
+	}
+	// being pushed might have put them past the edge, or into an obstacle
+	win := cfg.window()
+	for g := range moved {
+		if cfg.Depth > 0 {
+			g.Z, g.VZ = bounce(g.Z, g.VZ, float64(cfg.Depth))
+		}
+		win.Resolve(g, cfg.Boundary)
+		stayOutOfObstacles(g, cfg.Obstacles)
+	}
+}
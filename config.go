@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// NeighbourMode is how a goid decides which other goids are its neighbours
+type NeighbourMode int
+
+const (
+	Nearest NeighbourMode = iota // the Neighbours nearest goids
+	Radius                       // every goid within PerceptionRadius
+)
+
+var neighbourModes = []string{"nearest", "radius"}
+
+func (m NeighbourMode) String() string { return enumName(neighbourModes, int(m)) }
+
+// MarshalText lets the mode be used as a flag and in JSON by name
+func (m NeighbourMode) MarshalText() ([]byte, error) { return []byte(m.String()), nil }
+
+// UnmarshalText parses the name of a mode
+func (m *NeighbourMode) UnmarshalText(text []byte) error {
+	return parseEnum(neighbourModes, text, "neighbour mode", (*int)(m))
+}
+
+// name of the i'th value of an enum
+func enumName(names []string, i int) string {
+	if i >= 0 && i < len(names) {
+		return names[i]
+	}
+	return fmt.Sprintf("%d", i)
+}
+
+// set v to the index of text in the enum's names
+func parseEnum(names []string, text []byte, what string, v *int) error {
+	for i, name := range names {
+		if string(text) == name {
+			*v = i
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown %s %q, must be one of %s", what, text, strings.Join(names, ", "))
+}
+
+// Config holds the tunables of a simulation
+type Config struct {
+	Width            int            `json:"width"` // window size
+	Height           int            `json:"height"`
+	Depth            int            `json:"depth"` // how deep the space is, 0 for 2D and anything else for 3D
+	GoidSize         int            `json:"goid_size"`
+	MinMass          float64        `json:"min_mass"` // goids get a random mass in this range, heavier ones turn more sluggishly
+	MaxMass          float64        `json:"max_mass"`
+	GoidColor        color.RGBA     `json:"goid_color"`
+	Palette          Palette        `json:"palette"`          // colors goids one by one instead of all the same
+	BackgroundColor  color.RGBA     `json:"background_color"` // transparent if its alpha is 0, but that can look different across GIF viewers
+	Population       int            `json:"population"`
+	Spawn            SpawnPattern   `json:"spawn"`         // where the flock starts out
+	SpawnHeading     SpawnHeading   `json:"spawn_heading"` // which way the flock starts out flying
+	Loops            int            `json:"loops"`         // 0 for forever
+	NeighbourMode    NeighbourMode  `json:"neighbour_mode"`
+	NeighbourIndex   NeighbourIndex `json:"neighbour_index"`   // how neighbours are looked up, which only changes how fast
+	Metric           Metric         `json:"metric"`            // how distances between goids are measured
+	Neighbours       int            `json:"num_neighbours"`    // number of nearest neighbours each goid reacts to in Nearest mode, at most Population-1
+	SeparationFactor float64        `json:"separation_factor"` // the separation radius, neighbours any closer are pushed away from
+	AlignmentRadius  float64        `json:"alignment_radius"`  // only neighbours within these are aligned with and cohered to, 0 for all of them
+	CohesionRadius   float64        `json:"cohesion_radius"`
+	CohesionTheta    float64        `json:"cohesion_theta"`    // above 0, cohesion is over every goid within CohesionRadius, with clumps further away than this many times their size taken as a whole
+	SeparationWeight float64        `json:"separation_weight"` // each of the 3 rules steers in a unit direction, scaled by its weight
+	AlignmentWeight  float64        `json:"alignment_weight"`
+	CohesionWeight   float64        `json:"cohesion_weight"`
+	MaxSpeed         float64        `json:"max_speed"`
+	MaxForce         float64        `json:"max_force"`         // the most a goid can steer by in a step, which rounds off sharp turns, 0 for no limit
+	TimeStep         float64        `json:"time_step"`         // how much time each step covers, 1 is the classic one step a frame
+	PerceptionRadius float64        `json:"perception_radius"` // how far a goid can see in Radius mode, also the spatial grid cell size
+	FOV              float64        `json:"fov"`               // field of view in degrees, neighbours behind it are ignored
+	Seed             int64          `json:"seed"`              // seeds the random source, so the same seed gives the same run
+	Shape            Shape          `json:"shape"`
+	SizeMode         SizeMode       `json:"size_mode"`        // draw goids bigger the faster they go or the more crowded they are, between half and twice their size
+	TailScale        float64        `json:"tail_scale"`       // how many times its velocity a dot's whisker is long
+	TailSegments     int            `json:"tail_segments"`    // draw a tapering tail through this many of the last places a goid's been drawn instead of the whisker, 0 for the whisker
+	ColorBySpeed     bool           `json:"color_by_speed"`   // color goids from blue (slow) to red (fast) instead of their own color
+	ColorByDensity   bool           `json:"color_by_density"` // color goids by how crowded they are, mixed with the speed color if both are set
+	TrailFade        float64        `json:"trail_fade"`       // how much of the last frame fades away each frame, leaving trails, 0 turns them off
+	DebugNeighbours  bool           `json:"debug_neighbours"` // draw lines from each goid to its neighbours and its separation radius, slow for big flocks
+	ShowStats        bool           `json:"show_stats"`       // print the frame rate, loop, population and average speed into a corner of the frame
+	ShowCenter       bool           `json:"show_center"`      // mark the flock's center of mass with a crosshair and draw its bounding box
+	RenderSample     int            `json:"render_sample"`    // only draw every this many goids, by ID so it's the same ones each frame, 0 or 1 draws them all
+	Boundary         BoundaryMode   `json:"boundary"`
+	WindowShape      WindowShape    `json:"window_shape"`    // the shape of the space the flock's kept inside, inside the frame
+	Margin           float64        `json:"margin"`          // goids within this distance of a wall turn back, 0 turns it off
+	TurnFactor       float64        `json:"turn_factor"`     // how hard goids turn back at the deepest part of the margin
+	WanderStrength   float64        `json:"wander_strength"` // size of a random steer added every frame, 0 turns it off
+	WindStrength     float64        `json:"wind_strength"`   // how hard the wind pushes, 0 for no wind
+	WindScale        float64        `json:"wind_scale"`      // roughly how far apart the gusts and swirls of the wind are
+	Collide          bool           `json:"collide"`         // push apart goids that overlap after each step, so they never sit on top of each other
+	Attractors       []Attractor    `json:"attractors"`
+	Obstacles        []Obstacle     `json:"obstacles"`
+	Species          []Species      `json:"species"` // if set, these make up the population instead of the top level parameters
+	PredatorCount    int            `json:"predator_count"`
+	PredatorSpeed    float64        `json:"predator_speed"`  // top speed of predators, a bit over MaxSpeed makes for a fair chase
+	DangerRadius     float64        `json:"danger_radius"`   // goids flee predators closer than this
+	EatRadius        float64        `json:"eat_radius"`      // predators eat prey closer than this, 0 means they never do
+	PredatorEnergy   float64        `json:"predator_energy"` // frames a predator can go without eating, it splits in two at double this
+	EatEnergy        float64        `json:"eat_energy"`      // energy a predator gains from each prey it eats
+	PreyBirthRate    float64        `json:"prey_birth_rate"` // chance of each goid breeding per step, falling off as the flock nears Population
+	MaxAge           int            `json:"max_age"`         // goids fade out as they age and respawn somewhere else once they're older than this many steps, 0 for never
+	Leaders          int            `json:"leaders"`         // number of goids that fly LeaderPath instead of flocking
+	LeaderPath       LeaderPath     `json:"leader_path"`
+	LeaderRadius     float64        `json:"leader_radius"`   // size of the leaders' path
+	LeaderPeriod     int            `json:"leader_period"`   // steps it takes leaders to go once round their path
+	LeaderPull       float64        `json:"leader_pull"`     // how many times harder leaders pull on the goids around them than other goids do
+	ConvergeChange   float64        `json:"converge_change"` // stop early once polarization changes by less than this over ConvergeFrames steps, 0 never stops early
+	ConvergeFrames   int            `json:"converge_frames"`
+}
+
+// the coherence factor the simulation was tuned with before the rules were
+// weighted, the divisor on the pull towards neighbours
+const defaultCoherence = 8
+
+// the cohesion weight that pulls as a coherence factor used to, where the old
+// default is a weight of 1
+func cohesionWeightFor(coherence float64) float64 { return defaultCoherence / coherence }
+
+// DefaultConfig returns the parameters the simulation was originally tuned with
+func DefaultConfig() Config {
+	goidSize := 3
+	return Config{
+		Width:            800,
+		Height:           600,
+		GoidSize:         goidSize,
+		MinMass:          1,
+		MaxMass:          1,
+		GoidColor:        color.RGBA{200, 200, 100, 255}, // pale yellow, opaque
+		BackgroundColor:  color.RGBA{0, 0, 0, 255},       // black, opaque
+		Population:       150,
+		Loops:            100,
+		Neighbours:       7,
+		SeparationFactor: float64(goidSize * 5),
+		SeparationWeight: 1.5, // every rule is a unit push, separation a little stronger to keep goids from bunching up
+		AlignmentWeight:  1,
+		CohesionWeight:   1,
+		MaxSpeed:         10,
+		TimeStep:         1,
+		PerceptionRadius: 100,
+		WindScale:        200,
+		TailScale:        1,
+		FOV:              360,
+		TurnFactor:       1,
+		PredatorSpeed:    12,
+		DangerRadius:     80,
+		PredatorEnergy:   300,
+		EatEnergy:        60,
+		PreyBirthRate:    0.01,
+		ConvergeFrames:   50,
+		LeaderRadius:     200,
+		LeaderPeriod:     300,
+		LeaderPull:       5,
+	}
+}
+
+// Validate checks the config is one a simulation can actually run with
+func (c Config) Validate() error {
+	switch {
+	case c.Width <= 0 || c.Height <= 0:
+		return fmt.Errorf("window size must be positive, got %dx%d", c.Width, c.Height)
+	case c.Depth < 0:
+		return fmt.Errorf("depth must not be negative, got %d", c.Depth)
+	case c.GoidSize <= 0:
+		return fmt.Errorf("goid size must be positive, got %d", c.GoidSize)
+	case c.MinMass <= 0 || c.MaxMass < c.MinMass:
+		return fmt.Errorf("mass range must be positive and not backwards, got %g to %g", c.MinMass, c.MaxMass)
+	case c.Population <= 0:
+		return fmt.Errorf("population must be positive, got %d", c.Population)
+	case c.Loops < 0:
+		return fmt.Errorf("loops must not be negative, got %d", c.Loops)
+	case c.Neighbours <= 0:
+		return fmt.Errorf("neighbours must be positive, got %d", c.Neighbours)
+	case c.SeparationFactor < 0:
+		return fmt.Errorf("separation must not be negative, got %g", c.SeparationFactor)
+	case c.AlignmentRadius < 0 || c.CohesionRadius < 0:
+		return fmt.Errorf("alignment and cohesion radii must not be negative, got %g and %g", c.AlignmentRadius, c.CohesionRadius)
+	case c.CohesionTheta < 0:
+		return fmt.Errorf("cohesion theta must not be negative, got %g", c.CohesionTheta)
+	case c.CohesionTheta > 0 && c.CohesionRadius <= 0:
+		return fmt.Errorf("cohesion radius must be positive to use cohesion theta, got %g", c.CohesionRadius)
+	case c.SeparationWeight < 0 || c.AlignmentWeight < 0 || c.CohesionWeight < 0:
+		return fmt.Errorf("rule weights must not be negative, got %g, %g and %g", c.SeparationWeight, c.AlignmentWeight, c.CohesionWeight)
+	case c.MaxSpeed <= 0:
+		return fmt.Errorf("max speed must be positive, got %g", c.MaxSpeed)
+	case c.Margin < 0 || c.TurnFactor < 0:
+		return fmt.Errorf("margin and turn factor must not be negative, got %g and %g", c.Margin, c.TurnFactor)
+	case c.FOV <= 0 || c.FOV > 360:
+		return fmt.Errorf("field of view must be between 0 and 360 degrees, got %g", c.FOV)
+	case c.WanderStrength < 0:
+		return fmt.Errorf("wander strength must not be negative, got %g", c.WanderStrength)
+	case c.WindStrength < 0:
+		return fmt.Errorf("wind strength must not be negative, got %g", c.WindStrength)
+	case c.WindStrength > 0 && c.WindScale <= 0:
+		return fmt.Errorf("wind scale must be positive, got %g", c.WindScale)
+	case c.PredatorCount < 0:
+		return fmt.Errorf("predators must not be negative, got %d", c.PredatorCount)
+	case c.PredatorCount > 0 && (c.PredatorSpeed <= 0 || c.DangerRadius <= 0):
+		return fmt.Errorf("predator speed and danger radius must be positive, got %g and %g", c.PredatorSpeed, c.DangerRadius)
+	case c.EatRadius < 0:
+		return fmt.Errorf("eat radius must not be negative, got %g", c.EatRadius)
+	case c.EatRadius > 0 && (c.PredatorEnergy <= 0 || c.EatEnergy < 0 || c.PreyBirthRate < 0):
+		return fmt.Errorf("predator energy must be positive and eat energy and prey birth rate not negative, got %g, %g and %g", c.PredatorEnergy, c.EatEnergy, c.PreyBirthRate)
+	case c.MaxAge < 0:
+		return fmt.Errorf("max age must not be negative, got %d", c.MaxAge)
+	case c.TailScale < 0 || c.TailSegments < 0:
+		return fmt.Errorf("tail scale and segments must not be negative, got %g and %d", c.TailScale, c.TailSegments)
+	case c.RenderSample < 0:
+		return fmt.Errorf("render sample must not be negative, got %d", c.RenderSample)
+	case c.TrailFade < 0 || c.TrailFade > 1:
+		return fmt.Errorf("trail fade must be between 0 and 1, got %g", c.TrailFade)
+	case c.MaxForce < 0:
+		return fmt.Errorf("max force must not be negative, got %g", c.MaxForce)
+	case c.TimeStep <= 0:
+		return fmt.Errorf("time step must be positive, got %g", c.TimeStep)
+	case c.Leaders < 0 || c.Leaders > c.Population:
+		return fmt.Errorf("leaders must be between 0 and the population, got %d", c.Leaders)
+	case c.Leaders > 0 && (c.LeaderRadius <= 0 || c.LeaderPeriod <= 0 || c.LeaderPull <= 0):
+		return fmt.Errorf("leader radius, period and pull must be positive, got %g, %d and %g", c.LeaderRadius, c.LeaderPeriod, c.LeaderPull)
+	case c.ConvergeChange < 0:
+		return fmt.Errorf("convergence change must not be negative, got %g", c.ConvergeChange)
+	case c.ConvergeChange > 0 && c.ConvergeFrames <= 0:
+		return fmt.Errorf("convergence frames must be positive, got %d", c.ConvergeFrames)
+	case c.PerceptionRadius <= 0:
+		return fmt.Errorf("perception radius must be positive, got %g", c.PerceptionRadius)
+	}
+	for i, o := range c.Obstacles {
+		if o.Radius <= 0 {
+			return fmt.Errorf("obstacle %d: radius must be positive, got %g", i, o.Radius)
+		}
+	}
+	for i, sp := range c.Species {
+		if err := sp.validate(); err != nil {
+			return fmt.Errorf("species %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// parse a color written as #rrggbb or #rrggbbaa, where a missing alpha means
+// opaque
+func parseHexColor(s string) (c color.RGBA, err error) {
+	c.A = 255
+	switch len(s) {
+	case 7:
+		_, err = fmt.Sscanf(s, "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	case 9:
+		_, err = fmt.Sscanf(s, "#%02x%02x%02x%02x", &c.R, &c.G, &c.B, &c.A)
+	default:
+		err = fmt.Errorf("bad length")
+	}
+	if err != nil {
+		return c, fmt.Errorf("invalid color %q, must be #rrggbb or #rrggbbaa", s)
+	}
+	// color.RGBA is alpha-premultiplied
+	c.R = uint8(uint32(c.R) * uint32(c.A) / 255)
+	c.G = uint8(uint32(c.G) * uint32(c.A) / 255)
+	c.B = uint8(uint32(c.B) * uint32(c.A) / 255)
+	return
+}
+
+// the JSON names of the config's fields by their Go names in lower case,
+// which is what they went by in files written before they had JSON names
+var configNames = func() map[string]string {
+	names := make(map[string]string)
+	t := reflect.TypeFor[Config]()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		names[strings.ToLower(f.Name)] = name
+	}
+	return names
+}()
+
+// a config in JSON with the fields named by their Go names, as files
+// written before they had JSON names have them, renamed to what they're
+// called now
+func renameConfigFields(data []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		return data, err
+	}
+	renamed := make(map[string]json.RawMessage, len(fields))
+	for name, value := range fields {
+		if now, ok := configNames[strings.ToLower(name)]; ok {
+			name = now
+		}
+		renamed[name] = value
+	}
+	return json.Marshal(renamed)
+}
+
+// plainConfig is Config without its UnmarshalJSON, to decode into once the
+// fields have been renamed
+type plainConfig Config
+
+// UnmarshalJSON reads a config with its fields named either way, so
+// simulations saved before they had JSON names still load
+func (c *Config) UnmarshalJSON(data []byte) error {
+	data, err := renameConfigFields(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, (*plainConfig)(c))
+}
+
+// read a config from a JSON file over cfg, so whatever it leaves out stays as
+// it was. It looks like the config in a saved simulation, for example
+// {"population": 300, "obstacles": [{"pos": {"x": 400, "y": 300}, "radius": 50}]},
+// though the fields can go by their Go names too, like "Population", as they
+// did in files written before they had JSON names. A name that isn't one of
+// the config's is an error rather than quietly doing nothing. seeded and
+// timed say whether the file gave a seed and a time step, as any it gives,
+// even a seed of 0, are ones to keep.
+func loadConfig(path string, cfg *Config) (seeded, timed bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, false, err
+	}
+	renamed, err := renameConfigFields(data)
+	if err == nil {
+		// not through UnmarshalJSON, which can't tell unknown fields
+		dec := json.NewDecoder(bytes.NewReader(renamed))
+		dec.DisallowUnknownFields()
+		err = dec.Decode((*plainConfig)(cfg))
+	}
+	if err != nil {
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			return false, false, fmt.Errorf("reading config from %s line %d: %v", path, 1+bytes.Count(data[:syntax.Offset], []byte("\n")), err)
+		}
+		return false, false, fmt.Errorf("reading config from %s: %v", path, err)
+	}
+	if err = cfg.Validate(); err != nil {
+		return false, false, fmt.Errorf("config in %s: %v", path, err)
+	}
+	// it's been read once already, so this can't go wrong
+	var given struct {
+		Seed     *int64   `json:"seed"`
+		TimeStep *float64 `json:"time_step"`
+	}
+	json.Unmarshal(renamed, &given)
+	return given.Seed != nil, given.TimeStep != nil, nil
+}
+
+// write the seed and the config a run starts with, as JSON that -config
+// takes, so any run can be had again
+func printConfig(w io.Writer, cfg Config) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("writing config: %v", err)
+	}
+	_, err = fmt.Fprintf(w, "seed %d\nconfig %s\n", cfg.Seed, data)
+	return err
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"image/color"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// write a config file into a test's temporary directory
+func writeConfig(t *testing.T, data []byte) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigRoundTrip(t *testing.T) {
+	withEverything := DefaultConfig()
+	withEverything.Seed = 99
+	withEverything.Palette = Palette{Colors: []color.RGBA{{255, 0, 0, 255}, {0, 0, 255, 255}}}
+	withEverything.Obstacles = []Obstacle{{Pos: Vec2{400, 300}, Radius: 50}}
+	withEverything.Attractors = []Attractor{{Pos: Vec2{100, 100}, Strength: -2}}
+	withEverything.Species = []Species{
+		{Count: 100, Color: color.RGBA{200, 200, 100, 255}, SeparationWeight: 5, AlignmentWeight: 1, CohesionWeight: 2, MaxSpeed: 12},
+		{Count: 50, Color: color.RGBA{100, 200, 200, 255}, SeparationWeight: 1, AlignmentWeight: 2, CohesionWeight: 1, MaxSpeed: 8},
+	}
+	withEverything.Population = 150
+	for _, want := range []Config{DefaultConfig(), withEverything} {
+		data, err := json.Marshal(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, data := range [][]byte{data, goNames(t, data)} {
+			var got Config
+			if _, _, err := loadConfig(writeConfig(t, data), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("read back\n%+v\nwant\n%+v", got, want)
+			}
+			// and the same in a saved simulation
+			got = Config{}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("unmarshaled\n%+v\nwant\n%+v", got, want)
+			}
+		}
+	}
+}
+
+// a config in JSON with its fields named by their Go names, like files
+// written before they had JSON names
+func goNames(t *testing.T, data []byte) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	old := make(map[string]json.RawMessage)
+	ty := reflect.TypeFor[Config]()
+	for i := range ty.NumField() {
+		f := ty.Field(i)
+		if v, ok := fields[f.Tag.Get("json")]; ok {
+			old[f.Name] = v
+		}
+	}
+	if len(old) != len(fields) {
+		t.Fatalf("%d of the %d fields have Go names", len(old), len(fields))
+	}
+	data, err := json.Marshal(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestConfigSeeded(t *testing.T) {
+	tests := []struct {
+		file          string
+		seeded, timed bool
+	}{
+		{`{"population": 300}`, false, false},
+		{`{"seed": 7}`, true, false},
+		{`{"seed": 0}`, true, false},
+		{`{"time_step": 0.5}`, false, true},
+		{`{"seed": 7, "time_step": 1}`, true, true},
+		// by the names files had before the fields had JSON names
+		{`{"Population": 300}`, false, false},
+		{`{"Seed": 7}`, true, false},
+		{`{"TimeStep": 0.5}`, false, true},
+		{`{"timestep": 0.5}`, false, true},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		seeded, timed, err := loadConfig(writeConfig(t, []byte(tt.file)), &cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seeded != tt.seeded || timed != tt.timed {
+			t.Errorf("%s gave a seed: %v and a time step: %v, want %v and %v", tt.file, seeded, timed, tt.seeded, tt.timed)
+		}
+	}
+}
+
+func TestConfigUnknownFields(t *testing.T) {
+	for _, file := range []string{
+		`{"populaton": 300}`,
+		`{"CoherenceFactor": 8}`, // a field that's gone
+		`{"species": [{"count": 100, "max_sped": 4}]}`,
+	} {
+		cfg := DefaultConfig()
+		if _, _, err := loadConfig(writeConfig(t, []byte(file)), &cfg); err == nil {
+			t.Errorf("%s read without an error", file)
+		}
+	}
+}
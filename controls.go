@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// how much + and - change the selected parameter by each press
+const bumpFactor = 1.1
+
+// how many goids a and d add or remove each press
+const addCount = 10
+
+// controls are the keys for pausing, stepping and tuning a run as it goes:
+//
+//	space  pause or carry on
+//	.      take a single step while paused, or go forward a frame after ,
+//	,      go back a frame while paused, as far as the history goes
+//	s, c   pick separation distance or cohesion weight to tune
+//	+, -   raise or lower the picked parameter by 10%
+//	a, d   add or remove 10 goids
+type controls struct {
+	paused   bool
+	cohesion bool     // tuning the cohesion weight rather than the separation distance
+	history  *history // the last few frames, to go back through, nil for none
+}
+
+// handle any keys pressed since the last frame, between steps so the
+// simulation never sees a half changed config. While paused, it waits for
+// keys until it's told to carry on, take a step, or ctx is cancelled,
+// calling status whenever there's something new to show, or redraw when
+// the flock has been put back to an earlier frame.
+func (c *controls) handle(ctx context.Context, sim *Simulation, keys <-chan byte, status, redraw func()) {
+	if c.history != nil {
+		c.history.record(sim)
+	}
+	for {
+		var k byte
+		if c.paused {
+			select {
+			case k = <-keys:
+			case <-ctx.Done():
+				return
+			}
+		} else {
+			select {
+			case k = <-keys:
+			default:
+				return
+			}
+		}
+		step, scrubbed := c.key(sim, k)
+		if scrubbed {
+			redraw()
+		} else {
+			status()
+		}
+		if step {
+			return
+		}
+	}
+}
+
+// apply a key to the simulation, and whether it was a single step or moved
+// the flock through the history
+func (c *controls) key(sim *Simulation, k byte) (step, scrubbed bool) {
+	cfg := &sim.Config
+	switch k {
+	case ' ':
+		c.paused = !c.paused
+	case '.':
+		if c.paused && c.history != nil && c.history.back > 0 {
+			return false, c.history.scrub(sim, 1)
+		}
+		return c.paused, false
+	case ',':
+		if c.paused && c.history != nil {
+			return false, c.history.scrub(sim, -1)
+		}
+	case 's':
+		c.cohesion = false
+	case 'c':
+		c.cohesion = true
+	case '+', '=':
+		c.bump(cfg, bumpFactor)
+	case '-', '_':
+		c.bump(cfg, 1/bumpFactor)
+	case 'a':
+		sim.Add(addCount)
+	case 'd':
+		sim.Remove(addCount)
+	}
+	return false, false
+}
+
+// scale the picked parameter
+func (c *controls) bump(cfg *Config, by float64) {
+	if c.cohesion {
+		cfg.CohesionWeight *= by
+	} else {
+		cfg.SeparationFactor *= by
+	}
+}
+
+// a summary of the controls for the status line
+func (c *controls) status(cfg Config) string {
+	sep, coh := fmt.Sprintf("separation %.1f", cfg.SeparationFactor), fmt.Sprintf("cohesion %.2f", cfg.CohesionWeight)
+	if c.cohesion {
+		coh = "[" + coh + "]"
+	} else {
+		sep = "[" + sep + "]"
+	}
+	s := fmt.Sprintf(" %s %s goids %d", sep, coh, cfg.Population)
+	if c.paused {
+		s += " paused"
+	}
+	if c.history != nil && c.history.back > 0 {
+		s += fmt.Sprintf(" %d back", c.history.back)
+	}
+	return s
+}
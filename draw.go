@@ -0,0 +1,384 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	imagedraw "image/draw"
+	"log/slog"
+	"math"
+	"sort"
+
+	"github.com/llgcode/draw2d/draw2dimg"
+)
+
+// Shape is how goids are drawn
+type Shape int
+
+const (
+	Dot      Shape = iota // a circle with a tail behind it
+	Triangle              // an arrowhead pointing the way it's heading
+)
+
+var shapes = []string{"dot", "triangle"}
+
+func (s Shape) String() string { return enumName(shapes, int(s)) }
+
+// MarshalText lets the shape be used as a flag and in JSON by name
+func (s Shape) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+// UnmarshalText parses the name of a shape
+func (s *Shape) UnmarshalText(text []byte) error {
+	return parseEnum(shapes, text, "shape", (*int)(s))
+}
+
+// SizeMode is what, if anything, a goid's drawn size goes by
+type SizeMode int
+
+const (
+	FixedSize     SizeMode = iota // every goid at its own radius
+	SizeBySpeed                   // bigger the faster it's going
+	SizeByDensity                 // bigger the more crowded it is
+)
+
+var sizeModes = []string{"fixed", "speed", "density"}
+
+func (m SizeMode) String() string { return enumName(sizeModes, int(m)) }
+
+// MarshalText lets the size mode be used as a flag and in JSON by name
+func (m SizeMode) MarshalText() ([]byte, error) { return []byte(m.String()), nil }
+
+// UnmarshalText parses the name of a size mode
+func (m *SizeMode) UnmarshalText(text []byte) error {
+	return parseEnum(sizeModes, text, "size mode", (*int)(m))
+}
+
+// how much smaller and bigger than its own radius a goid's drawn when its
+// size goes by speed or density, so none of them vanish or swamp the frame
+const (
+	minSizeScale = 0.5
+	maxSizeScale = 2.0
+)
+
+// draw the obstacles, the goids and the predators. With trails on, they're
+// drawn over a faded copy of the last frame.
+func draw(sim *Simulation) *image.RGBA {
+	dest := image.NewRGBA(image.Rect(0, 0, sim.Config.Width, sim.Config.Height))
+	bg := sim.Config.BackgroundColor
+	if fade := sim.Config.TrailFade; fade > 0 && sim.lastFrame != nil && sim.lastFrame.Rect == dest.Rect {
+		// fade the last frame towards the background
+		copy(dest.Pix, sim.lastFrame.Pix)
+		veil := image.NewUniform(scaleAlpha(bg, fade))
+		imagedraw.Draw(dest, dest.Rect, veil, image.Point{}, imagedraw.Over)
+	} else {
+		imagedraw.Draw(dest, dest.Rect, image.NewUniform(bg), image.Point{}, imagedraw.Src)
+	}
+	gc := draw2dimg.NewGraphicContext(dest)
+	for _, o := range sim.Config.Obstacles {
+		gc.SetFillColor(obstacleColor)
+		gc.MoveTo(o.Pos.X+o.Radius, o.Pos.Y)
+		gc.ArcTo(o.Pos.X, o.Pos.Y, o.Radius, o.Radius, 0, -math.Pi*2)
+		gc.Close()
+		gc.Fill()
+	}
+	gc.SetStrokeColor(windowEdgeColor)
+	gc.SetLineWidth(1)
+	gc.BeginPath()
+	sim.Config.window().trace(gc)
+	gc.Stroke()
+	if sim.Config.DebugNeighbours {
+		if sim.Config.WindStrength > 0 {
+			drawWind(gc, sim)
+		}
+		drawNeighbours(gc, sim)
+	}
+	species := sim.Config.species()
+	var bounds flockBounds
+	undrawable := 0
+	sim.draws++
+	for _, goid := range sim.depthOrder() {
+		c := sim.colorOf(goid, species[goid.Species])
+		goid := sim.project(sim.sized(goid, species[goid.Species]))
+		var tail []Vec2
+		if sim.Config.TailSegments > 0 && goid.Pos.finite() {
+			tail = sim.tailOf(goid)
+		}
+		if !drawGoid(gc, goid, c, sim.Config.Shape, sim.Config.TailScale, tail) {
+			undrawable++
+			continue
+		}
+		bounds.add(goid.Pos)
+	}
+	if sim.Config.TailSegments > 0 {
+		sim.pruneTails()
+	}
+	for _, p := range sim.Predators {
+		if !drawGoid(gc, sim.project(p.Goid), p.Color, Triangle, 0, nil) {
+			undrawable++
+		}
+	}
+	// only said when it changes, not every frame
+	if undrawable != sim.undrawable {
+		slog.Warn("skipped drawing goids that are nowhere", "count", undrawable)
+		sim.undrawable = undrawable
+	}
+	if sim.Config.ShowCenter && bounds.n > 0 {
+		drawBounds(gc, bounds)
+	}
+	if sim.Config.TrailFade > 0 {
+		sim.lastFrame = dest
+	}
+	// stats go on after the trail is kept, so old numbers don't smear
+	if sim.Config.ShowStats {
+		drawStats(dest, sim)
+	}
+	return dest
+}
+
+// draw a goid, unless it's somewhere it can't be drawn, like at NaN after
+// something's gone wrong in the sums. Whatever else goes wrong drawing it
+// only loses the one goid, not the whole frame. A dot's whisker is its
+// velocity times tailScale long, unless it's given a tail of where it's been
+// instead. It returns whether the goid was drawn.
+func drawGoid(gc *draw2dimg.GraphicContext, g *Goid, c color.Color, shape Shape, tailScale float64, tail []Vec2) (ok bool) {
+	if !g.Pos.finite() || !g.Vel.finite() {
+		return false
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Debug("drawing goid", "id", g.ID, "pos", g.Pos, "err", r)
+			gc.BeginPath()
+			ok = false
+		}
+	}()
+	if len(tail) > 1 {
+		drawTail(gc, tail, c, float64(g.R))
+		tailScale = 0
+	}
+	gc.SetFillColor(c)
+	if shape == Triangle {
+		drawTriangle(gc, g)
+	} else {
+		whisker := g.Pos.Sub(g.Vel.Scale(tailScale))
+		gc.MoveTo(g.Pos.X, g.Pos.Y)
+		gc.ArcTo(g.Pos.X, g.Pos.Y, float64(g.R), float64(g.R), 0, -math.Pi*2)
+		gc.LineTo(whisker.X, whisker.Y)
+		gc.Close()
+	}
+	gc.Fill()
+	return true
+}
+
+// the goids to draw in the order to draw them in, which in 3D is furthest
+// first so nearer goids are drawn over them. Sampling picks goids by ID,
+// which they keep, so the same ones are drawn every frame.
+func (sim *Simulation) depthOrder() []*Goid {
+	n := sim.Config.RenderSample
+	if sim.Config.Depth <= 0 && n <= 1 {
+		return sim.Goids
+	}
+	goids := make([]*Goid, 0, len(sim.Goids))
+	for _, g := range sim.Goids {
+		if n <= 1 || g.ID%n == 0 {
+			goids = append(goids, g)
+		}
+	}
+	if sim.Config.Depth <= 0 {
+		return goids
+	}
+	sort.SliceStable(goids, func(i, j int) bool { return goids[i].Z > goids[j].Z })
+	return goids
+}
+
+// a copy of the goid flattened onto the frame. In 3D it's a perspective
+// view from in front of the space, with the back half the size of the
+// front, so goids further away are drawn smaller and nearer the middle.
+func (sim *Simulation) project(g Goid) *Goid {
+	if depth := float64(sim.Config.Depth); depth > 0 {
+		scale := depth / (depth + g.Z)
+		centre := Vec2{float64(sim.Config.Width) / 2, float64(sim.Config.Height) / 2}
+		g.Pos = centre.Add(g.Pos.Sub(centre).Scale(scale))
+		g.Vel = g.Vel.Scale(scale)
+		g.R = max(1, int(math.Round(float64(g.R)*scale)))
+	}
+	return &g
+}
+
+// a dull red that goids fade to as they reach MaxAge
+var dyingColor = color.RGBA{90, 40, 40, 255}
+
+// the color to draw a goid in, faded towards dyingColor as it gets old
+func (sim *Simulation) colorOf(g *Goid, sp Species) color.Color {
+	c := sim.liveColor(g, sp)
+	if maxAge := sim.Config.MaxAge; maxAge > 0 && !g.Leader {
+		return blend(color.RGBAModel.Convert(c).(color.RGBA), dyingColor, float64(g.Age)/float64(maxAge))
+	}
+	return c
+}
+
+// the color of a goid before it's aged, its own color unless it's a leader or
+// it's colored by speed or density, or a mix of the two if by both
+func (sim *Simulation) liveColor(g *Goid, sp Species) color.Color {
+	cfg := sim.Config
+	switch {
+	case g.Leader:
+		return leaderColor
+	case cfg.ColorBySpeed && cfg.ColorByDensity:
+		return mix(speedColor(g.speed()/sp.MaxSpeed), densityColor(float64(g.Density)/float64(cfg.Neighbours)))
+	case cfg.ColorBySpeed:
+		return speedColor(g.speed() / sp.MaxSpeed)
+	case cfg.ColorByDensity:
+		return densityColor(float64(g.Density) / float64(cfg.Neighbours))
+	}
+	return g.Color
+}
+
+// a copy of the goid with its radius going by its speed or how crowded it is,
+// from the same fractions as its color, unless it's a leader
+func (sim *Simulation) sized(g *Goid, sp Species) Goid {
+	cfg := sim.Config
+	sized := *g
+	var f float64
+	switch {
+	case g.Leader || cfg.SizeMode == FixedSize:
+		return sized
+	case cfg.SizeMode == SizeBySpeed:
+		f = g.speed() / sp.MaxSpeed
+	case cfg.SizeMode == SizeByDensity:
+		f = float64(g.Density) / float64(cfg.Neighbours)
+	}
+	f = math.Max(0, math.Min(1, f))
+	scale := minSizeScale + (maxSizeScale-minSizeScale)*f
+	sized.R = max(1, int(math.Round(float64(g.R)*scale)))
+	return sized
+}
+
+// a color made more transparent by f, between 0 and 1, keeping in mind
+// color.RGBA is alpha-premultiplied
+func scaleAlpha(c color.RGBA, f float64) color.RGBA {
+	f = math.Max(0, math.Min(1, f))
+	return color.RGBA{uint8(float64(c.R) * f), uint8(float64(c.G) * f), uint8(float64(c.B) * f), uint8(float64(c.A) * f)}
+}
+
+// the way from a to b by f, between 0 and 1
+func blend(a, b color.RGBA, f float64) color.RGBA {
+	f = math.Max(0, math.Min(1, f))
+	lerp := func(x, y uint8) uint8 { return uint8(float64(x) + (float64(y)-float64(x))*f) }
+	return color.RGBA{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B), lerp(a.A, b.A)}
+}
+
+// halfway between two colors
+func mix(a, b color.RGBA) color.RGBA {
+	return color.RGBA{
+		uint8((int(a.R) + int(b.R)) / 2),
+		uint8((int(a.G) + int(b.G)) / 2),
+		uint8((int(a.B) + int(b.B)) / 2),
+		uint8((int(a.A) + int(b.A)) / 2),
+	}
+}
+
+// map how crowded a goid is, as a fraction of the number of neighbours it
+// reacts to, onto a ramp from dim green for stragglers to glowing yellow for
+// the dense cores of clusters
+func densityColor(density float64) color.RGBA {
+	density = math.Max(0, math.Min(1, density))
+	return color.RGBA{uint8(40 + 215*density), uint8(120 + 135*density), uint8(60 + 60*density), 255}
+}
+
+// map a speed, as a fraction of the top speed, onto a gradient from blue for
+// standing still to red for flat out
+func speedColor(speed float64) color.RGBA {
+	speed = math.Max(0, math.Min(1, speed))
+	return color.RGBA{uint8(255 * speed), 40, uint8(255 * (1 - speed)), 255}
+}
+
+// draw a thin line from each goid to each of the neighbours it sees where it
+// is now, and a ring showing how close they can get before it pushes them away
+func drawNeighbours(gc *draw2dimg.GraphicContext, sim *Simulation) {
+	cfg := sim.Config
+	ix := sim.index()
+	k := sim.neighbourCount()
+	gc.SetLineWidth(0.5)
+	for _, goid := range sim.Goids {
+		gc.SetStrokeColor(neighbourLineColor)
+		gc.BeginPath()
+		for _, n := range sim.neighbours(nil, ix, goid, k, goid.inView(cfg.FOV)) {
+			gc.MoveTo(goid.Pos.X, goid.Pos.Y)
+			gc.LineTo(n.Pos.X, n.Pos.Y)
+		}
+		gc.Stroke()
+		gc.SetStrokeColor(separationRingColor)
+		gc.BeginPath()
+		gc.MoveTo(goid.Pos.X+cfg.SeparationFactor, goid.Pos.Y)
+		gc.ArcTo(goid.Pos.X, goid.Pos.Y, cfg.SeparationFactor, cfg.SeparationFactor, 0, -math.Pi*2)
+		gc.Close()
+		gc.Stroke()
+	}
+}
+
+// flockBounds adds up where the goids are as they're drawn, for the center
+// of mass and the box around them
+type flockBounds struct {
+	n        int
+	sum      Vec2
+	min, max Vec2
+}
+
+func (b *flockBounds) add(p Vec2) {
+	if b.n == 0 {
+		b.min, b.max = p, p
+	}
+	b.n++
+	b.sum = b.sum.Add(p)
+	b.min = Vec2{math.Min(b.min.X, p.X), math.Min(b.min.Y, p.Y)}
+	b.max = Vec2{math.Max(b.max.X, p.X), math.Max(b.max.Y, p.Y)}
+}
+
+// how big the arms of the center of mass crosshair are
+const crosshairSize = 6
+
+// draw a crosshair on the center of mass and the box around the flock
+func drawBounds(gc *draw2dimg.GraphicContext, b flockBounds) {
+	c := b.sum.Scale(1 / float64(b.n))
+	gc.SetStrokeColor(boundsColor)
+	gc.SetLineWidth(1)
+	gc.BeginPath()
+	gc.MoveTo(b.min.X, b.min.Y)
+	gc.LineTo(b.max.X, b.min.Y)
+	gc.LineTo(b.max.X, b.max.Y)
+	gc.LineTo(b.min.X, b.max.Y)
+	gc.Close()
+	gc.MoveTo(c.X-crosshairSize, c.Y)
+	gc.LineTo(c.X+crosshairSize, c.Y)
+	gc.MoveTo(c.X, c.Y-crosshairSize)
+	gc.LineTo(c.X, c.Y+crosshairSize)
+	gc.Stroke()
+}
+
+var (
+	boundsColor         = color.RGBA{200, 200, 80, 255}
+	neighbourLineColor  = color.RGBA{0, 90, 90, 255}
+	separationRingColor = color.RGBA{90, 30, 30, 255}
+)
+
+// trace an isosceles triangle around the goid, pointing the way it's heading
+func drawTriangle(gc *draw2dimg.GraphicContext, g *Goid) {
+	tip, left, right := trianglePoints(g)
+	gc.MoveTo(tip.X, tip.Y)
+	gc.LineTo(left.X, left.Y)
+	gc.LineTo(right.X, right.Y)
+	gc.Close()
+}
+
+// the corners of the triangle a goid is drawn as
+func trianglePoints(g *Goid) (tip, left, right Vec2) {
+	heading := g.Vel.Normalize()
+	if heading == (Vec2{}) {
+		heading = Vec2{1, 0}
+	}
+	side := Vec2{-heading.Y, heading.X}
+	r := float64(g.R)
+	tip = g.Pos.Add(heading.Scale(r * 2))
+	left = g.Pos.Sub(heading.Scale(r)).Add(side.Scale(r))
+	right = g.Pos.Sub(heading.Scale(r)).Sub(side.Scale(r))
+	return
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata with what's drawn now")
+
+// the first frame of a run with the given seed
+func firstFrame(seed int64) []byte {
+	cfg := DefaultConfig()
+	cfg.Seed = seed
+	sim := NewSimulation(cfg)
+	sim.Step()
+	return draw(sim).Pix
+}
+
+func TestSameSeedSameFrame(t *testing.T) {
+	if !bytes.Equal(firstFrame(42), firstFrame(42)) {
+		t.Error("two runs with the same seed drew different first frames")
+	}
+	if bytes.Equal(firstFrame(42), firstFrame(43)) {
+		t.Error("runs with different seeds drew the same first frame")
+	}
+}
+
+// a small flock a few steps in, drawn as triangles with tails, against the
+// frame it drew when the test was last updated
+func TestGoldenFrame(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Width, cfg.Height = 160, 120
+	cfg.Population = 20
+	cfg.Seed = 1
+	cfg.Shape = Triangle
+	cfg.TailSegments = 5
+	sim := NewSimulation(cfg)
+	var frame *image.RGBA
+	for i := 0; i < 10; i++ {
+		sim.Step()
+		frame = draw(sim)
+	}
+	golden := filepath.Join("testdata", "frame.png")
+	if *update {
+		var b bytes.Buffer
+		if err := png.Encode(&b, frame); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, b.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := os.Open(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := image.NewRGBA(img.Bounds())
+	for y := want.Rect.Min.Y; y < want.Rect.Max.Y; y++ {
+		for x := want.Rect.Min.X; x < want.Rect.Max.X; x++ {
+			want.Set(x, y, img.At(x, y))
+		}
+	}
+	if want.Rect != frame.Rect {
+		t.Fatalf("drew a %v frame, want %v", frame.Rect, want.Rect)
+	}
+	if !bytes.Equal(frame.Pix, want.Pix) {
+		differ := 0
+		for i := 0; i < len(frame.Pix); i += 4 {
+			if !bytes.Equal(frame.Pix[i:i+4], want.Pix[i:i+4]) {
+				differ++
+			}
+		}
+		t.Errorf("%d pixels differ from %s, run the test with -update if that's meant to be", differ, golden)
+	}
+}
+
+// goids that are nowhere are left out, and the rest of the frame's drawn
+// just as it would be without them
+func TestDrawSkipsGoidsThatAreNowhere(t *testing.T) {
+	for _, shape := range []Shape{Dot, Triangle} {
+		cfg := DefaultConfig()
+		cfg.Width, cfg.Height = 160, 120
+		cfg.Population = 10
+		cfg.Shape = shape
+		good := NewSimulation(cfg)
+		bad := NewSimulation(cfg)
+		bad.Goids[2].Pos = Vec2{math.NaN(), 50}
+		bad.Goids[5].Vel = Vec2{math.Inf(1), 0}
+		bad.Goids[7].Pos = Vec2{math.Inf(-1), math.NaN()}
+		nowhere := map[int]bool{bad.Goids[2].ID: true, bad.Goids[5].ID: true, bad.Goids[7].ID: true}
+		good.Goids = slices.DeleteFunc(good.Goids, func(g *Goid) bool { return nowhere[g.ID] })
+		want := draw(good)
+		got := draw(bad)
+		if bad.undrawable != 3 {
+			t.Errorf("%v: %d goids couldn't be drawn, want 3", shape, bad.undrawable)
+		}
+		if !bytes.Equal(got.Pix, want.Pix) {
+			t.Errorf("%v: the frame with goids that are nowhere isn't the frame without them", shape)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// ImageFormat is how frames are encoded when they're sent or saved as images
+type ImageFormat int
+
+const (
+	PNG  ImageFormat = iota // lossless, and keeps a transparent background
+	JPEG                    // much smaller, but lossy and with no transparency
+)
+
+var imageFormats = []string{"png", "jpeg"}
+
+func (f ImageFormat) String() string { return enumName(imageFormats, int(f)) }
+
+// MarshalText lets the format be used as a flag and in JSON by name
+func (f ImageFormat) MarshalText() ([]byte, error) { return []byte(f.String()), nil }
+
+// UnmarshalText parses the name of a format
+func (f *ImageFormat) UnmarshalText(text []byte) error {
+	return parseEnum(imageFormats, text, "image format", (*int)(f))
+}
+
+// encoder writes frames out in a format
+type encoder struct {
+	format  ImageFormat
+	quality int // JPEG quality, from 1 to 100
+}
+
+func (e encoder) encode(w io.Writer, img image.Image) error {
+	if e.format == JPEG {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: e.quality})
+	}
+	return png.Encode(w, img)
+}
+
+// the file extension for the format
+func (e encoder) ext() string {
+	if e.format == JPEG {
+		return ".jpg"
+	}
+	return ".png"
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// the frame rate a time step of 1 was tuned at, so at any other -fps each step
+// covers as much time as a frame does against it, and the flock crosses the
+// screen just as fast
+const referenceFPS = 30
+
+// the time step for frames shown fps times a second
+func frameTimeStep(fps int) float64 { return referenceFPS / float64(fps) }
+
+// options are the command line settings that control the program rather
+// than the simulation
+type options struct {
+	gif      string // write the run to this GIF file instead of the terminal
+	gifDelay int    // 100ths of a second per GIF frame
+	gifLoop  int    // GIF loop count, 0 is forever and -1 is once
+	gifMax   int    // most frames to put in the GIF, 0 for every loop
+	frames   string // write each frame as a PNG into this directory instead of the terminal
+	terminal string // which terminal image protocol to use
+	fps      int    // most frames a second to show in the terminal, 0 for as fast as possible
+	mouse    bool   // the flock chases the mouse, or runs from it while a button is held
+	noKeys   bool   // don't read keys from the terminal
+	save     string // save the simulation here as JSON when the run ends
+	csv      string // record every goid's position and velocity each frame to this CSV file
+	replay   string // show a run recorded with csv instead of simulating one
+	load     string // start from a simulation saved with save
+	serve    string // serve the run to browsers on this address instead of the terminal
+	anySite  bool   // let pages from any site watch and tune a served run
+	fit      bool   // size the window to fill the terminal
+	svg      string // write the last frame to this SVG file
+	enc      encoder
+	quiet    bool   // don't show the status line above the frame
+	headless bool   // only run the simulation, drawing and showing nothing
+	history  int    // how many frames back a paused run can be stepped
+	freeze   bool   // hold a converged run on screen until a key is pressed
+	cpuProf  string // write a CPU profile of the run to this file
+	memProf  string // write a memory profile to this file when the run ends
+}
+
+// find the value of a flag in the command line, before it's parsed. If it's
+// there more than once, the last one counts like it does for any flag.
+func argValue(args []string, flag string) (value string, ok bool) {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, v, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != flag {
+			continue
+		}
+		if !hasValue {
+			if i+1 == len(args) {
+				break
+			}
+			v = args[i+1]
+		}
+		value, ok = v, true
+	}
+	return
+}
+
+// parse the command line flags over the default config, or the preset's, exiting with a
+// usage error if the result doesn't make sense
+func parseFlags() (cfg Config, opts options) {
+	// the preset and the config file are the defaults the other flags
+	// change, so they have to be known before they are
+	var preset Preset
+	if name, ok := argValue(os.Args[1:], "preset"); ok {
+		if err := preset.UnmarshalText([]byte(name)); err != nil {
+			fmt.Fprintln(os.Stderr, "goids:", err)
+			flag.Usage()
+			os.Exit(2)
+		}
+	}
+	cfg = preset.Config()
+	// whether the seed and the time step have been given, so they aren't
+	// made up from the clock and the frame rate
+	seeded, timed := false, cfg.TimeStep != DefaultConfig().TimeStep
+	if path, ok := argValue(os.Args[1:], "config"); ok {
+		fileSeeded, fileTimed, err := loadConfig(path, &cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "goids:", err)
+			os.Exit(2)
+		}
+		seeded, timed = fileSeeded, timed || fileTimed
+	}
+	flag.TextVar(&preset, "preset", preset, "start from a named set of parameters, which the other flags then change: flock, gas, swarm, school or vortex")
+	flag.String("config", "", "JSON file of parameters to start from, over the preset, with the same names as the config in a file written by -save, which the other flags then change")
+	flag.IntVar(&cfg.Population, "population", cfg.Population, "number of goids")
+	flag.TextVar(&cfg.Spawn, "spawn", cfg.Spawn, "where the flock starts out: random, cluster, ring, grid or groups")
+	flag.TextVar(&cfg.SpawnHeading, "spawn-heading", cfg.SpawnHeading, "which way the flock starts out flying: random, outward (away from the middle of the pattern) or inward")
+	flag.IntVar(&cfg.Loops, "loops", cfg.Loops, "number of frames to run for, 0 to run until interrupted")
+	flag.TextVar(&cfg.NeighbourMode, "neighbour-mode", cfg.NeighbourMode, "how goids pick their neighbours: nearest (a fixed count) or radius (all within the perception radius)")
+	flag.TextVar(&cfg.Metric, "metric", cfg.Metric, "how the distance between goids is measured: euclidean, manhattan or chebyshev")
+	flag.TextVar(&cfg.NeighbourIndex, "neighbour-index", cfg.NeighbourIndex, "how neighbours are looked up: grid, kdtree (faster for tightly clustered flocks) or brute (every pair, slow)")
+	flag.Float64Var(&cfg.PerceptionRadius, "perception", cfg.PerceptionRadius, "how far goids can see in radius mode")
+	flag.IntVar(&cfg.Neighbours, "neighbours", cfg.Neighbours, "number of nearest neighbours each goid reacts to")
+	flag.Float64Var(&cfg.SeparationFactor, "separation", cfg.SeparationFactor, "distance goids try to keep from their neighbours")
+	flag.Float64Var(&cfg.AlignmentRadius, "alignment-radius", cfg.AlignmentRadius, "only align with neighbours this close, 0 for all of them")
+	flag.Float64Var(&cfg.CohesionRadius, "cohesion-radius", cfg.CohesionRadius, "only cohere to neighbours this close, 0 for all of them")
+	flag.Float64Var(&cfg.CohesionTheta, "cohesion-theta", cfg.CohesionTheta, "cohere to every goid within -cohesion-radius instead of just the neighbours, taking clumps further away than this many times their size as one, so bigger is faster and rougher. 0 for just the neighbours. Only in 2D without -boundary wrap.")
+	flag.Float64Var(&cfg.SeparationWeight, "separation-weight", cfg.SeparationWeight, "weight of the separation rule, higher spreads the flock out")
+	flag.Float64Var(&cfg.AlignmentWeight, "alignment-weight", cfg.AlignmentWeight, "weight of the alignment rule, higher makes long streams heading the same way")
+	flag.Float64Var(&cfg.CohesionWeight, "cohesion-weight", cfg.CohesionWeight, "weight of the cohesion rule, higher makes tight clusters")
+	coherence := flag.Float64("coherence", defaultCoherence, "divisor on the pull towards neighbours, higher is weaker. It sets -cohesion-weight to 8 over it, so 16 is a weight of 0.5.")
+	flag.IntVar(&cfg.Width, "width", cfg.Width, "window width in pixels")
+	flag.IntVar(&cfg.Height, "height", cfg.Height, "window height in pixels")
+	flag.BoolVar(&opts.fit, "fit", false, "size the window to fill the terminal instead of -width and -height, if the terminal says how big it is in pixels")
+	flag.Float64Var(&cfg.MinMass, "min-mass", cfg.MinMass, "lightest a goid can be, heavier goids turn more slowly")
+	flag.Float64Var(&cfg.MaxMass, "max-mass", cfg.MaxMass, "heaviest a goid can be")
+	flag.IntVar(&cfg.Depth, "depth", cfg.Depth, "depth of the space in pixels, to fly the flock in 3D, 0 for 2D")
+	flag.TextVar(&cfg.Boundary, "boundary", cfg.Boundary, "what goids do at the edges: wrap or bounce")
+	flag.TextVar(&cfg.WindowShape, "window", cfg.WindowShape, "shape of the space the flock's kept in: rectangle (the whole frame) or circle")
+	flag.Float64Var(&cfg.FOV, "fov", cfg.FOV, "field of view in degrees, neighbours outside it are ignored")
+	flag.Float64Var(&cfg.Margin, "margin", cfg.Margin, "distance from the walls at which goids start turning back, 0 for none")
+	flag.Float64Var(&cfg.TurnFactor, "turn", cfg.TurnFactor, "how hard goids turn back from the walls")
+	flag.Float64Var(&cfg.WanderStrength, "wander", cfg.WanderStrength, "strength of a random steer each frame to keep the flock exploring, 0 for none")
+	flag.Float64Var(&cfg.WindStrength, "wind", cfg.WindStrength, "strength of a wind that swirls across the window and slowly changes, 0 for none")
+	flag.Float64Var(&cfg.WindScale, "wind-scale", cfg.WindScale, "roughly how far apart the wind's swirls are, in pixels")
+	flag.BoolVar(&cfg.Collide, "collide", cfg.Collide, "push apart goids that overlap after each step, so they can't pass through each other")
+	attractors := flag.String("attractors", "", "JSON file of points that attract (positive strength) or repel (negative strength) goids")
+	flag.IntVar(&cfg.PredatorCount, "predators", cfg.PredatorCount, "number of predators chasing the flock")
+	flag.Float64Var(&cfg.PredatorSpeed, "predator-speed", cfg.PredatorSpeed, "top speed of the predators")
+	flag.Float64Var(&cfg.DangerRadius, "danger", cfg.DangerRadius, "distance at which goids flee from a predator")
+	flag.Float64Var(&cfg.EatRadius, "eat", cfg.EatRadius, "distance at which predators eat prey, 0 for never")
+	flag.Float64Var(&cfg.PredatorEnergy, "predator-energy", cfg.PredatorEnergy, "frames a predator survives without eating")
+	flag.Float64Var(&cfg.EatEnergy, "eat-energy", cfg.EatEnergy, "energy a predator gains from each prey")
+	flag.Float64Var(&cfg.PreyBirthRate, "birth-rate", cfg.PreyBirthRate, "chance of each goid breeding per frame when predators eat")
+	flag.IntVar(&cfg.MaxAge, "max-age", cfg.MaxAge, "frames a goid lives before it fades out and respawns somewhere else, 0 to live forever")
+	species := flag.String("species", "", "JSON file of species, each flocking only with its own kind, to use instead of -population")
+	obstacles := flag.String("obstacles", "", "JSON file of circular obstacles the flock flows around")
+	flag.Func("background", "background color as #rrggbb, or #rrggbbaa for transparency (default #000000)", func(s string) (err error) {
+		cfg.BackgroundColor, err = parseHexColor(s)
+		return
+	})
+	flag.TextVar(&cfg.Palette, "palette", cfg.Palette, "give each goid its own color, from the hue wheel (wheel) or in turn from a list like #ff0000,#00ff00,#0000ff, instead of its species' color")
+	flag.Float64Var(&cfg.TailScale, "tail-scale", cfg.TailScale, "how long a dot's whisker is, as a multiple of its velocity, 0 for none")
+	flag.IntVar(&cfg.TailSegments, "tail", cfg.TailSegments, "draw a tapering tail through each goid's last this many positions instead of the whisker, 0 for the whisker")
+	flag.TextVar(&cfg.Shape, "shape", cfg.Shape, "how goids are drawn: dot or triangle")
+	flag.TextVar(&cfg.SizeMode, "size", cfg.SizeMode, "what goids are drawn bigger for: fixed (neither), speed or density")
+	flag.BoolVar(&cfg.ColorBySpeed, "color-by-speed", cfg.ColorBySpeed, "color goids from blue when slow to red when fast")
+	flag.BoolVar(&cfg.ColorByDensity, "color-by-density", cfg.ColorByDensity, "color goids from green when alone to yellow when crowded")
+	flag.Float64Var(&cfg.TrailFade, "trail", cfg.TrailFade, "leave fading trails, the fraction of the last frame that fades each frame (0.1 is long, 0.5 short), 0 for none")
+	flag.BoolVar(&cfg.DebugNeighbours, "debug-neighbours", cfg.DebugNeighbours, "draw lines from each goid to its neighbours, and its separation radius")
+	flag.IntVar(&cfg.RenderSample, "render-sample", cfg.RenderSample, "only draw every Nth goid, still simulating all of them, for watching flocks too big to draw every frame")
+	flag.BoolVar(&cfg.ShowCenter, "show-com", cfg.ShowCenter, "mark the flock's center of mass with a crosshair and draw the box around it, to see whether it's holding together or drifting apart")
+	flag.BoolVar(&cfg.ShowStats, "stats", cfg.ShowStats, "show the frame rate, loop, population and average speed in a corner of the frame")
+	flag.Float64Var(&cfg.MaxForce, "max-force", cfg.MaxForce, "most a goid can steer by each frame, smaller makes for smoother curves, 0 for no limit")
+	flag.Float64Var(&cfg.TimeStep, "dt", cfg.TimeStep, "time each step covers, smaller steps are smoother but slower to cover ground. It normally goes by -fps, 1 at 30 frames a second, unless -config gives one, and this fixes it whatever the frame rate.")
+	flag.IntVar(&cfg.Leaders, "leaders", cfg.Leaders, "number of goids that fly a set path for the rest to follow")
+	flag.TextVar(&cfg.LeaderPath, "leader-path", cfg.LeaderPath, "path the leaders fly: circle or figure8")
+	flag.Float64Var(&cfg.LeaderRadius, "leader-radius", cfg.LeaderRadius, "size of the leaders' path in pixels")
+	flag.IntVar(&cfg.LeaderPeriod, "leader-period", cfg.LeaderPeriod, "frames it takes the leaders to go once round their path")
+	flag.Float64Var(&cfg.LeaderPull, "leader-pull", cfg.LeaderPull, "how many times harder a leader pulls the goids around it than other goids do")
+	flag.Float64Var(&cfg.ConvergeChange, "converge", cfg.ConvergeChange, "stop once the flock's polarization changes by less than this over -converge-frames frames, 0 to always run every loop")
+	flag.BoolVar(&opts.freeze, "freeze", false, "when the run converges, hold the last frame in the terminal with a note saying so, until space carries on or any other key quits")
+	flag.IntVar(&cfg.ConvergeFrames, "converge-frames", cfg.ConvergeFrames, "number of frames polarization has to hold steady for to count as converged")
+	flag.Int64Var(&cfg.Seed, "seed", cfg.Seed, "random seed, defaults to the one in -config or else one based on the current time")
+	flag.StringVar(&opts.gif, "gif", "", "write the frames to this animated GIF file instead of the terminal")
+	flag.IntVar(&opts.gifDelay, "gif-delay", 4, "time each GIF frame is shown, in 100ths of a second")
+	flag.IntVar(&opts.gifLoop, "gif-loop", 0, "number of times the GIF repeats, 0 for forever and -1 to play once")
+	flag.IntVar(&opts.gifMax, "gif-frames", 0, "most frames to put in the GIF, which ends the run once it has them, 0 for every one of -loops. The frames are kept in memory until the GIF is written, so one of the two has to be set.")
+	flag.StringVar(&opts.svg, "svg", "", "write the last frame to this SVG file when the run ends")
+	flag.TextVar(&opts.enc.format, "format", opts.enc.format, "how frames are encoded for -frames and iTerm: png, or jpeg which is much smaller but loses any transparent background")
+	flag.IntVar(&opts.enc.quality, "quality", 90, "JPEG quality, from 1 to 100")
+	flag.StringVar(&opts.frames, "frames", "", "write each frame as a numbered PNG into this directory instead of the terminal")
+	flag.StringVar(&opts.terminal, "terminal", "auto", "terminal to render for: auto, iterm, kitty, sixel, blocks, braille or ascii")
+	flag.StringVar(&opts.csv, "csv", "", "record each goid's position and velocity every frame to this CSV file")
+	flag.StringVar(&opts.replay, "replay", "", "play back a run recorded with -csv instead of simulating, drawn with the current flags")
+	flag.StringVar(&opts.save, "save", "", "save the simulation to this JSON file when the run ends")
+	flag.StringVar(&opts.load, "load", "", "start from a simulation saved with -save, using its config instead of the flags")
+	flag.BoolVar(&opts.noKeys, "no-keys", false, "don't take keys from the terminal, which normally pause (space), step (.), step back (,) and tune (s, c, + and -) the run, and add or remove goids (a and d)")
+	flag.BoolVar(&opts.mouse, "mouse", false, "have the flock chase the mouse in the terminal, and flee it while a button is held")
+	flag.StringVar(&opts.cpuProf, "cpuprofile", "", "write a CPU profile of the run to this file, for go tool pprof")
+	flag.StringVar(&opts.memProf, "memprofile", "", "write a memory profile to this file when the run ends, for go tool pprof")
+	flag.IntVar(&opts.history, "history", 150, "how many of the last frames to keep, so a paused run can be stepped back through them with , and forward again with ., 0 for none")
+	flag.BoolVar(&opts.quiet, "quiet", false, "don't show the status line above the frame in the terminal")
+	flag.BoolVar(&opts.headless, "headless", false, "only run the simulation, without drawing or showing anything, for timing and profiling it. With -stats the final stats are printed when it ends.")
+	flag.IntVar(&opts.fps, "fps", 30, "most frames a second to show in the terminal or send to browsers, 0 for as fast as it can go. Unless -dt or -config says otherwise, each step covers the time of a frame, so the flock moves as fast on screen at any frame rate.")
+	flag.StringVar(&opts.serve, "serve", "", "serve the run to browsers on this address, like :8080, instead of the terminal. It starts when the first one connects and stops when the last one leaves. Tune it as it goes with GET and POST /config, and watch it with GET /stats, or scrape /metrics with Prometheus.")
+	flag.BoolVar(&opts.anySite, "serve-any-origin", false, "let pages from any site watch and tune a run served with -serve. Normally only its own page and pages from this machine can, so another site open in the browser can't change it.")
+	verbose := flag.Bool("v", false, "log what the run's doing to stderr")
+	veryVerbose := flag.Bool("vv", false, "log what the run's doing and how long each frame takes to stderr")
+	flag.Parse()
+	switch {
+	case *veryVerbose:
+		setupLogging(2)
+	case *verbose:
+		setupLogging(1)
+	default:
+		setupLogging(0)
+	}
+
+	if *attractors != "" {
+		var err error
+		if cfg.Attractors, err = loadAttractors(*attractors); err != nil {
+			fmt.Fprintln(os.Stderr, "goids:", err)
+			os.Exit(2)
+		}
+	}
+	if *species != "" {
+		var err error
+		if cfg.Species, err = loadSpecies(*species); err != nil {
+			fmt.Fprintln(os.Stderr, "goids:", err)
+			os.Exit(2)
+		}
+	}
+	if *obstacles != "" {
+		var err error
+		if cfg.Obstacles, err = loadObstacles(*obstacles); err != nil {
+			fmt.Fprintln(os.Stderr, "goids:", err)
+			os.Exit(2)
+		}
+	}
+	if opts.fit {
+		if w, h, ok := terminalFit(); ok {
+			cfg.Width, cfg.Height = w, h
+		} else {
+			slog.Warn("can't tell the terminal's size in pixels, keeping the window size", "width", cfg.Width, "height", cfg.Height)
+		}
+	}
+	if !seeded && !isFlagSet("seed") {
+		cfg.Seed = time.Now().UnixNano()
+	}
+
+	if opts.enc.quality < 1 || opts.enc.quality > 100 {
+		fmt.Fprintln(os.Stderr, "goids: quality must be between 1 and 100, got", opts.enc.quality)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if opts.gifMax < 0 {
+		fmt.Fprintln(os.Stderr, "goids: gif frames must not be negative, got", opts.gifMax)
+		flag.Usage()
+		os.Exit(2)
+	}
+	// a replay ends with its file, but a run with no end would fill up
+	// memory with frames
+	if opts.gif != "" && opts.gifMax == 0 && cfg.Loops == 0 && opts.replay == "" {
+		fmt.Fprintln(os.Stderr, "goids: -gif needs -loops or -gif-frames to say when it ends")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if opts.history < 0 {
+		fmt.Fprintln(os.Stderr, "goids: history must not be negative, got", opts.history)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if opts.fps < 0 {
+		fmt.Fprintln(os.Stderr, "goids: fps must not be negative, got", opts.fps)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if isFlagSet("coherence") {
+		if *coherence <= 0 {
+			fmt.Fprintln(os.Stderr, "goids: coherence must be positive, got", *coherence)
+			flag.Usage()
+			os.Exit(2)
+		}
+		if isFlagSet("cohesion-weight") {
+			fmt.Fprintln(os.Stderr, "goids: -coherence and -cohesion-weight both set the cohesion weight, give one of them")
+			flag.Usage()
+			os.Exit(2)
+		}
+		cfg.CohesionWeight = cohesionWeightFor(*coherence)
+	}
+	// as fast as it can go has no frame time to go by
+	if !timed && !isFlagSet("dt") && opts.fps > 0 {
+		cfg.TimeStep = frameTimeStep(opts.fps)
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, "goids:", err)
+		flag.Usage()
+		os.Exit(2)
+	}
+	return
+}
+
+// whether the named flag was given on the command line
+func isFlagSet(name string) (set bool) {
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return
+}
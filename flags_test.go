@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"testing"
+)
+
+// parse args as the command line, with none of the flags defined yet
+func parseArgs(t *testing.T, args ...string) (Config, options) {
+	osArgs, commandLine := os.Args, flag.CommandLine
+	t.Cleanup(func() { os.Args, flag.CommandLine = osArgs, commandLine })
+	os.Args = append([]string{"goids"}, args...)
+	flag.CommandLine = flag.NewFlagSet("goids", flag.ExitOnError)
+	return parseFlags()
+}
+
+func TestTimeStepFlags(t *testing.T) {
+	timed := writeConfig(t, []byte(`{"time_step": 0.25}`))
+	untimed := writeConfig(t, []byte(`{"population": 300}`))
+	tests := []struct {
+		args []string
+		want float64
+	}{
+		{nil, 1},
+		{[]string{"-fps", "60"}, 0.5},
+		{[]string{"-fps", "0"}, 1},
+		{[]string{"-fps", "60", "-dt", "2"}, 2},
+		// a config file's time step is kept whatever the frame rate
+		{[]string{"-config", timed}, 0.25},
+		{[]string{"-config", timed, "-fps", "60"}, 0.25},
+		{[]string{"-config", timed, "-dt", "2"}, 2},
+		{[]string{"-config", untimed, "-fps", "60"}, 0.5},
+	}
+	for _, tt := range tests {
+		if cfg, _ := parseArgs(t, tt.args...); cfg.TimeStep != tt.want {
+			t.Errorf("%q gave a time step of %g, want %g", tt.args, cfg.TimeStep, tt.want)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+)
+
+// write a frame into dir as a PNG named frame_NNNN.png, where NNNN is the
+// 1-based frame number zero-padded to 4 digits (frame_0001.png, frame_0002.png
+// and so on), so the sequence can be put together with something like
+// ffmpeg -framerate 30 -i dir/frame_%04d.png out.mp4. As a JPEG it's
+// frame_NNNN.jpg.
+func writeFrame(dir string, n int, frame image.Image, enc encoder) error {
+	path := filepath.Join(dir, fmt.Sprintf("frame_%04d%s", n, enc.ext()))
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = enc.encode(f, frame); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s: %v", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"image"
+	"image/color/palette"
+	imagedraw "image/draw"
+	"image/gif"
+	"os"
+)
+
+// gifRecorder accumulates frames into an animated GIF. They're all kept in
+// memory until it's written, so it only takes so many.
+type gifRecorder struct {
+	anim      gif.GIF
+	delay     int
+	maxFrames int // 0 for no limit, when the run has one
+}
+
+// delay is the time each frame is shown in 100ths of a second, loopCount is
+// the number of times the animation repeats, 0 for forever and -1 for once,
+// and maxFrames the most frames it takes, 0 for as many as it's given
+func newGIFRecorder(delay, loopCount, maxFrames int) *gifRecorder {
+	return &gifRecorder{
+		anim:      gif.GIF{LoopCount: loopCount},
+		delay:     delay,
+		maxFrames: maxFrames,
+	}
+}
+
+// whether the animation has all the frames it takes
+func (r *gifRecorder) full() bool {
+	return r.maxFrames > 0 && len(r.anim.Image) >= r.maxFrames
+}
+
+// add a frame to the animation, unless it's full. Every frame is mapped onto
+// the same fixed palette without dithering, so colors don't flicker between
+// frames.
+func (r *gifRecorder) add(frame *image.RGBA) {
+	if r.full() {
+		return
+	}
+	img := image.NewPaletted(frame.Bounds(), palette.Plan9)
+	imagedraw.Draw(img, img.Rect, frame, frame.Rect.Min, imagedraw.Src)
+	r.anim.Image = append(r.anim.Image, img)
+	r.anim.Delay = append(r.anim.Delay, r.delay)
+}
+
+// write the animation out to a file
+func (r *gifRecorder) save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = gif.EncodeAll(f, &r.anim); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestGIFRecorderMaxFrames(t *testing.T) {
+	rec := newGIFRecorder(4, 0, 3)
+	frame := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := 0; i < 5; i++ {
+		if rec.full() != (i >= 3) {
+			t.Fatalf("full after %d frames is %v", i, rec.full())
+		}
+		rec.add(frame)
+	}
+	if n := len(rec.anim.Image); n != 3 {
+		t.Errorf("kept %d frames, want 3", n)
+	}
+	if len(rec.anim.Delay) != len(rec.anim.Image) {
+		t.Errorf("%d delays for %d frames", len(rec.anim.Delay), len(rec.anim.Image))
+	}
+}
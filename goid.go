@@ -0,0 +1,78 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+)
+
+// Goid represents a drawn goid
+type Goid struct {
+	ID          int     // stays the same for the life of the goid, unique within a simulation
+	Pos         Vec2    // position
+	Vel         Vec2    // velocity
+	Z           float64 // depth into the screen in 3D mode, always 0 in 2D
+	VZ          float64 // velocity into the screen
+	R           int     // radius
+	Mass        float64 // divides the steer, so heavier goids are slower to turn
+	Color       color.Color
+	Species     int     // index into the simulation's species
+	Density     int     // number of neighbours within the perception radius in the last step
+	Age         int     // steps since it was spawned
+	Leader      bool    // flies a set path instead of flocking, and the flock is drawn to it
+	LeaderPhase float64 // how far round the path a leader started, as a fraction of a lap
+}
+
+// a new goid starts out at age 0, so a goid respawned from this is young again
+func createRandomGoid(cfg Config, rng *rand.Rand, id int) (g Goid) {
+	g = Goid{
+		ID:    id,
+		Pos:   Vec2{rng.Float64() * float64(cfg.Width), rng.Float64() * float64(cfg.Height)},
+		Vel:   Vec2{rng.Float64() * float64(cfg.GoidSize), rng.Float64() * float64(cfg.GoidSize)},
+		R:     cfg.GoidSize,
+		Color: cfg.Palette.color(id, cfg.GoidColor),
+		Mass:  cfg.MinMass,
+	}
+	g.Pos = cfg.window().fit(g.Pos)
+	// only draw a mass if there's a range, so the default flock is the
+	// same as it ever was
+	if cfg.MaxMass > cfg.MinMass {
+		g.Mass += rng.Float64() * (cfg.MaxMass - cfg.MinMass)
+	}
+	if cfg.Depth > 0 {
+		g.Z = rng.Float64() * float64(cfg.Depth)
+		g.VZ = rng.Float64() * float64(cfg.GoidSize)
+	}
+	return
+}
+
+// find the k nearest neighbours that keep allows, not including the goid
+// itself
+func (g *Goid) nearestNeighbours(goids []*Goid, k int, keep func(*Goid) bool) (neighbours []Goid) {
+	neighbours, _ = selectNearest(nil, g, goids, k, keep, Euclidean)
+	return
+}
+
+// distance between 2 goids
+func (g *Goid) distance(n Goid) float64 {
+	return math.Sqrt(g.distanceSq(n))
+}
+
+// squared distance between 2 goids, cheaper than distance when only the
+// ordering matters
+func (g *Goid) distanceSq(n Goid) float64 {
+	d := n.pos3().Sub(g.pos3())
+	return d.Dot(d)
+}
+
+// the goid's position in 3D
+func (g *Goid) pos3() Vec3 { return Vec3{g.Pos.X, g.Pos.Y, g.Z} }
+
+// the goid's velocity in 3D
+func (g *Goid) vel3() Vec3 { return Vec3{g.Vel.X, g.Vel.Y, g.VZ} }
+
+// set the goid's velocity in 3D
+func (g *Goid) setVel3(v Vec3) { g.Vel, g.VZ = Vec2{v.X, v.Y}, v.Z }
+
+// how fast the goid is going, in 3D
+func (g *Goid) speed() float64 { return g.vel3().Len() }
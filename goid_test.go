@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// n goids scattered over the default window, the same ones for the same seed
+func randomGoids(n int, seed int64) []*Goid {
+	cfg := DefaultConfig()
+	rng := rand.New(rand.NewSource(seed))
+	goids := make([]*Goid, n)
+	for i := range goids {
+		g := createRandomGoid(cfg, rng, i+1)
+		goids[i] = &g
+	}
+	return goids
+}
+
+func TestNearestNeighboursLength(t *testing.T) {
+	goids := randomGoids(50, 1)
+	// a goid that isn't one of them has every one of them as a neighbour
+	outsider := &Goid{Pos: Vec2{400, 300}}
+	got := outsider.nearestNeighbours(goids, len(goids), nil)
+	if len(got) != len(goids) {
+		t.Fatalf("got %d neighbours of %d goids", len(got), len(goids))
+	}
+	for i, n := range got {
+		if n.ID == 0 {
+			t.Errorf("neighbour %d is a zero goid", i)
+		}
+	}
+}
+
+func TestNearestNeighboursExcludesSelf(t *testing.T) {
+	goids := randomGoids(50, 2)
+	for _, g := range goids {
+		for _, n := range g.nearestNeighbours(goids, len(goids), nil) {
+			if n.ID == g.ID {
+				t.Fatalf("goid %d is its own neighbour", g.ID)
+			}
+		}
+	}
+	// even one sitting on top of another is only the other's neighbour
+	a, b := &Goid{ID: 1, Pos: Vec2{10, 10}}, &Goid{ID: 2, Pos: Vec2{10, 10}}
+	got := a.nearestNeighbours([]*Goid{a, b}, 2, nil)
+	if len(got) != 1 || got[0].ID != b.ID {
+		t.Fatalf("got neighbours %v, want just goid 2", got)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b Goid
+		want float64
+	}{
+		{Goid{Pos: Vec2{0, 0}}, Goid{Pos: Vec2{3, 4}}, 5},
+		{Goid{Pos: Vec2{10, 20}}, Goid{Pos: Vec2{10, 20}}, 0},
+		{Goid{Pos: Vec2{1, 2}, Z: 3}, Goid{Pos: Vec2{1, 2}, Z: 3}, 0},
+		{Goid{Pos: Vec2{1, 1}, Z: 0}, Goid{Pos: Vec2{3, 4}, Z: 6}, 7},
+		{Goid{Pos: Vec2{-5, -5}}, Goid{Pos: Vec2{-8, -1}}, 5},
+		// far from the origin, where the squares are huge but nothing overflows
+		{Goid{Pos: Vec2{3e150, 0}}, Goid{Pos: Vec2{0, -4e150}}, 5e150},
+		{Goid{Pos: Vec2{1e9, 1e9}}, Goid{Pos: Vec2{1e9 + 3, 1e9 + 4}}, 5},
+	}
+	for _, tt := range tests {
+		for _, pair := range [][2]Goid{{tt.a, tt.b}, {tt.b, tt.a}} {
+			a, b := pair[0], pair[1]
+			got := a.distance(b)
+			if math.Abs(got-tt.want) > 1e-12*math.Max(1, tt.want) {
+				t.Errorf("distance from %v to %v = %g, want %g", a.Pos, b.Pos, got, tt.want)
+			}
+			if sq := a.distanceSq(b); math.Abs(sq-tt.want*tt.want) > 1e-12*math.Max(1, tt.want*tt.want) {
+				t.Errorf("squared distance from %v to %v = %g, want %g", a.Pos, b.Pos, sq, tt.want*tt.want)
+			}
+		}
+	}
+}
+
+func TestDistanceSymmetric(t *testing.T) {
+	goids := randomGoids(100, 2)
+	for _, a := range goids {
+		for _, b := range goids {
+			if a.distance(*b) != b.distance(*a) || a.distanceSq(*b) != b.distanceSq(*a) {
+				t.Fatalf("goids %d and %d are %g apart one way and %g the other", a.ID, b.ID, a.distance(*b), b.distance(*a))
+			}
+		}
+	}
+}
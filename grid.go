@@ -0,0 +1,181 @@
+package main
+
+import "math"
+
+// grid is a uniform spatial hash of goids, bucketed by cell coordinates. In
+// wrap mode it's a torus, the cells on one edge are next to the cells on the
+// other, and goids seen across an edge are where they'd be if the window
+// carried on past it.
+type grid struct {
+	space         // which in wrap mode is exactly cols x rows cells
+	cellW float64 // width of a cell
+	cellH float64 // height of a cell
+	cols  int
+	rows  int
+	cells [][]*Goid
+}
+
+// bucket the goids in a width x height window into cells of at least the
+// given size, this is rebuilt once per frame. It's built in the cells of an
+// old grid if there is one, which can't be used after that.
+func newGrid(goids []*Goid, size float64, width, height int, wrap bool, old [][]*Goid) *grid {
+	gr := &grid{
+		space: space{wrap: wrap, width: float64(width), height: float64(height)},
+		cellW: size,
+		cellH: size,
+		cols:  int(math.Ceil(float64(width)/size)) + 1,
+		rows:  int(math.Ceil(float64(height)/size)) + 1,
+	}
+	if wrap {
+		// the cells have to tile the window exactly to join up at the
+		// edges, so they're stretched a little rather than left over
+		gr.cols = max(1, int(float64(width)/size))
+		gr.rows = max(1, int(float64(height)/size))
+		gr.cellW = gr.width / float64(gr.cols)
+		gr.cellH = gr.height / float64(gr.rows)
+	}
+	if n := gr.cols * gr.rows; cap(old) >= n {
+		gr.cells = old[:n]
+		for i := range gr.cells {
+			gr.cells[i] = gr.cells[i][:0]
+		}
+	} else {
+		gr.cells = make([][]*Goid, n)
+	}
+	for _, goid := range goids {
+		c, r := gr.cell(goid.Pos)
+		gr.cells[r*gr.cols+c] = append(gr.cells[r*gr.cols+c], goid)
+	}
+	return gr
+}
+
+// the grid for the simulation's goids as they are now, in the last one's
+// cells
+func (s *Simulation) grid() *grid {
+	cfg := s.Config
+	gr := newGrid(s.Goids, cfg.PerceptionRadius, cfg.Width, cfg.Height, cfg.torus(), s.cells)
+	gr.metric = cfg.Metric
+	s.cells = gr.cells
+	return gr
+}
+
+// cell coordinates for a position, clamped to the grid
+func (gr *grid) cell(p Vec2) (c, r int) {
+	c = clamp(int(p.X/gr.cellW), 0, gr.cols-1)
+	r = clamp(int(p.Y/gr.cellH), 0, gr.rows-1)
+	return
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// the index of the cell dx columns and dy rows away from (c, r), or false if
+// there's no such cell. In wrap mode the offsets go round the edges, and a
+// cell is only found at the one offset between -(n-1)/2 and n/2 cells that
+// reaches it on a loop of n, so none is visited twice.
+func (gr *grid) neighbourCell(c, r, dx, dy int) (int, bool) {
+	x, y := c+dx, r+dy
+	if gr.wrap {
+		if dx < -(gr.cols-1)/2 || dx > gr.cols/2 || dy < -(gr.rows-1)/2 || dy > gr.rows/2 {
+			return 0, false
+		}
+		x, y = (x+gr.cols)%gr.cols, (y+gr.rows)%gr.rows
+	}
+	if x < 0 || y < 0 || x >= gr.cols || y >= gr.rows {
+		return 0, false
+	}
+	return y*gr.cols + x, true
+}
+
+// find the k nearest neighbours of g that keep allows, scanning its own cell
+// and the 8 adjacent cells first, and only widening the search ring if that
+// doesn't turn up k goids that are provably the closest
+func (gr *grid) nearestNeighbours(dst []Goid, g *Goid, k int, keep func(*Goid) bool) (neighbours []Goid) {
+	c, r := gr.cell(g.Pos)
+	maxRing := gr.cols
+	if gr.rows > maxRing {
+		maxRing = gr.rows
+	}
+	sc := scratchPool.Get().(*scratch)
+	defer scratchPool.Put(sc)
+	candidates := sc.candidates[:0]
+	sc.ghosts = sc.ghosts[:0]
+	// handed back whichever way this returns, grown as it may be
+	defer func() { sc.candidates = candidates[:0] }()
+	for ring := 0; ring <= maxRing; ring++ {
+		for dy := -ring; dy <= ring; dy++ {
+			for dx := -ring; dx <= ring; dx++ {
+				// only the cells on the edge of the ring are new
+				if dy != -ring && dy != ring && dx != -ring && dx != ring {
+					continue
+				}
+				if cell, ok := gr.neighbourCell(c, r, dx, dy); ok {
+					candidates = gr.appendSeen(candidates, &sc.ghosts, g, gr.cells[cell])
+				}
+			}
+		}
+		if ring == 0 {
+			continue
+		}
+		// everything within ring cells of g has been seen by now
+		var kthSq float64
+		// and whatever the metric, nothing nearer than reach can be
+		// further along either axis than that
+		neighbours, kthSq = selectNearest(dst, g, candidates, k, keep, gr.metric)
+		reach := float64(ring) * math.Min(gr.cellW, gr.cellH)
+		if kthSq >= 0 && kthSq <= reach*reach {
+			return
+		}
+	}
+	return
+}
+
+// find all the neighbours of g within radius that keep allows, in no
+// particular order. With the cell size at the radius, that's just g's own
+// cell and the 8 around it.
+func (gr *grid) neighboursWithin(dst []Goid, g *Goid, radius float64, keep func(*Goid) bool) (neighbours []Goid) {
+	neighbours = dst
+	sc := scratchPool.Get().(*scratch)
+	defer scratchPool.Put(sc)
+	gr.eachWithin(g, radius, func(n *Goid, pos Vec2) {
+		if seen := sc.see(n, pos); keep == nil || keep(seen) {
+			neighbours = append(neighbours, *seen)
+		}
+	})
+	return
+}
+
+// all the goids in the grid within radius of g, not including g
+func (gr *grid) within(g *Goid, radius float64) (goids []*Goid) {
+	gr.eachWithin(g, radius, func(n *Goid, _ Vec2) { goids = append(goids, n) })
+	return
+}
+
+// call f with each goid within radius of g, not including g, and the
+// position g sees it at, which in wrap mode might be across an edge
+func (gr *grid) eachWithin(g *Goid, radius float64, f func(n *Goid, pos Vec2)) {
+	c, r := gr.cell(g.Pos)
+	reachX := int(math.Ceil(radius / gr.cellW))
+	reachY := int(math.Ceil(radius / gr.cellH))
+	for dy := -reachY; dy <= reachY; dy++ {
+		for dx := -reachX; dx <= reachX; dx++ {
+			cell, ok := gr.neighbourCell(c, r, dx, dy)
+			if !ok {
+				continue
+			}
+			for _, n := range gr.cells[cell] {
+				pos := gr.seenFrom(g.Pos, n.Pos)
+				if n != g && gr.metric.distanceSq(g.pos3(), Vec3{pos.X, pos.Y, n.Z}) <= radius*radius {
+					f(n, pos)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// look up the nearest neighbours of every goid, as a step does, with the grid
+// and with checking every pair
+func BenchmarkGridNeighbours(b *testing.B) {
+	cfg := DefaultConfig()
+	for _, n := range []int{150, 1500, 15000} {
+		goids := randomGoids(n, 1)
+		sp := space{width: float64(cfg.Width), height: float64(cfg.Height)}
+		indexes := []struct {
+			name string
+			ix   func() neighbourIndex
+		}{
+			{"grid", func() neighbourIndex {
+				return newGrid(goids, cfg.PerceptionRadius, cfg.Width, cfg.Height, false, nil)
+			}},
+			{"brute", func() neighbourIndex { return &bruteForce{space: sp, goids: goids} }},
+		}
+		for _, x := range indexes {
+			b.Run(fmt.Sprintf("%s/n=%d", x.name, n), func(b *testing.B) {
+				var dst []Goid
+				for i := 0; i < b.N; i++ {
+					ix := x.ix()
+					for _, g := range goids {
+						dst = ix.nearestNeighbours(dst[:0], g, cfg.Neighbours, nil)
+					}
+				}
+			})
+		}
+	}
+}
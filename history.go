@@ -0,0 +1,65 @@
+package main
+
+// history keeps the goids as they were over the last few frames in a ring,
+// so a paused run can be stepped back through them. Each frame is a copy of
+// the goids by value, reusing the same slices once the ring is full.
+type history struct {
+	frames [][]Goid
+	steps  []int // the simulation's frame count at each of them
+	next   int   // where the next frame goes
+	n      int   // how many frames are kept, up to len(frames)
+	back   int   // how many frames back from the latest is being shown, 0 for the latest
+}
+
+// a history of the last size frames, nil if size isn't positive
+func newHistory(size int) *history {
+	if size <= 0 {
+		return nil
+	}
+	return &history{frames: make([][]Goid, size), steps: make([]int, size)}
+}
+
+// add the flock as it is now. If it was stepped back, the frames after the
+// one it went on from are forgotten, they're not what happened any more.
+func (h *history) record(sim *Simulation) {
+	if h.back > 0 {
+		h.next = (h.index(h.back) + 1) % len(h.frames)
+		h.n -= h.back
+		h.back = 0
+	}
+	frame := h.frames[h.next][:0]
+	for _, g := range sim.Goids {
+		frame = append(frame, *g)
+	}
+	h.frames[h.next], h.steps[h.next] = frame, sim.Frame
+	h.next = (h.next + 1) % len(h.frames)
+	h.n = min(h.n+1, len(h.frames))
+}
+
+// where the frame back from the latest is in the ring
+func (h *history) index(back int) int {
+	return (h.next - 1 - back + 2*len(h.frames)) % len(h.frames)
+}
+
+// move by frames through the history, back for negative and forward for
+// positive, putting the flock back how it was there. It returns whether it
+// moved at all, it stops at either end.
+func (h *history) scrub(sim *Simulation, by int) bool {
+	back := max(0, min(h.back-by, h.n-1))
+	if back == h.back || h.n == 0 {
+		return false
+	}
+	h.back = back
+	i := h.index(back)
+	frame := h.frames[i]
+	sim.Config.Population += len(frame) - len(sim.Goids)
+	for len(sim.Goids) < len(frame) {
+		sim.Goids = append(sim.Goids, new(Goid))
+	}
+	sim.Goids = sim.Goids[:len(frame)]
+	for j := range frame {
+		*sim.Goids[j] = frame[j]
+	}
+	sim.Frame = h.steps[i]
+	return true
+}
@@ -0,0 +1,165 @@
+package main
+
+// NeighbourIndex is the way goids' neighbours are looked up each step. They
+// all find the same neighbours, some just get there faster than others
+// depending on how the flock is spread out.
+type NeighbourIndex int
+
+const (
+	GridIndex   NeighbourIndex = iota // a uniform grid of cells the size of the perception radius
+	KDTreeIndex                       // a k-d tree, better when the flock is crowded into a few clusters
+	BruteIndex                        // checking every goid against every other, slow but simple
+)
+
+var neighbourIndexes = []string{"grid", "kdtree", "brute"}
+
+func (x NeighbourIndex) String() string { return enumName(neighbourIndexes, int(x)) }
+
+// MarshalText lets the index be used as a flag and in JSON by name
+func (x NeighbourIndex) MarshalText() ([]byte, error) { return []byte(x.String()), nil }
+
+// UnmarshalText parses the name of an index
+func (x *NeighbourIndex) UnmarshalText(text []byte) error {
+	return parseEnum(neighbourIndexes, text, "neighbour index", (*int)(x))
+}
+
+// neighbourIndex finds the goids near a goid, from a snapshot of the flock
+// taken when it was built. Neighbours are returned as g sees them, so in
+// wrap mode one seen across an edge is a copy moved to where it'd be if the
+// window carried on past it. They're appended to dst, so a slice can be
+// reused from one goid to the next.
+type neighbourIndex interface {
+	// the k nearest neighbours of g that keep allows, nearest first
+	nearestNeighbours(dst []Goid, g *Goid, k int, keep func(*Goid) bool) []Goid
+	// all the neighbours of g within radius that keep allows, in no
+	// particular order
+	neighboursWithin(dst []Goid, g *Goid, radius float64, keep func(*Goid) bool) []Goid
+	// the goids themselves within radius of g, not including g
+	within(g *Goid, radius float64) []*Goid
+	// squared distance between 2 goids, the short way round in wrap mode
+	distanceSq(a, b *Goid) float64
+}
+
+// the neighbour index for the simulation's goids as they are now
+func (s *Simulation) index() neighbourIndex {
+	cfg := s.Config
+	sp := space{wrap: cfg.torus(), width: float64(cfg.Width), height: float64(cfg.Height), metric: cfg.Metric}
+	switch cfg.NeighbourIndex {
+	case KDTreeIndex:
+		return newKDTree(s.Goids, sp)
+	case BruteIndex:
+		return &bruteForce{space: sp, goids: s.Goids}
+	}
+	return s.grid()
+}
+
+// space is the window the goids fly in, which in wrap mode is a torus, and
+// how distances are measured in it
+type space struct {
+	wrap   bool
+	width  float64
+	height float64
+	metric Metric
+}
+
+// where p is as seen from from, which in wrap mode is the nearest of its
+// copies in the windows tiled all around this one
+func (sp space) seenFrom(from, p Vec2) Vec2 {
+	if !sp.wrap {
+		return p
+	}
+	return Vec2{
+		from.X + wrapOffset(p.X-from.X, sp.width),
+		from.Y + wrapOffset(p.Y-from.Y, sp.height),
+	}
+}
+
+// append goids to seen as g sees them. Goids seen across an edge are copies
+// moved to where g sees them, kept in ghosts if it's not nil.
+func (sp space) appendSeen(seen []*Goid, ghosts *[]Goid, g *Goid, goids []*Goid) []*Goid {
+	if !sp.wrap {
+		return append(seen, goids...)
+	}
+	for _, n := range goids {
+		if pos := sp.seenFrom(g.Pos, n.Pos); pos != n.Pos {
+			ghost := newGhost(ghosts)
+			*ghost = *n
+			ghost.Pos = pos
+			n = ghost
+		}
+		seen = append(seen, n)
+	}
+	return seen
+}
+
+// n moved to pos, where it's seen from, in the scratch's one goid for that.
+// Filters only get to look at it until the next one's seen.
+func (sc *scratch) see(n *Goid, pos Vec2) *Goid {
+	sc.seen = *n
+	sc.seen.Pos = pos
+	return &sc.seen
+}
+
+// room for a ghost at the end of ghosts. It's only ever appended to within
+// its capacity, and replaced when full, so the ghosts already handed out
+// stay where they are.
+func newGhost(ghosts *[]Goid) *Goid {
+	if ghosts == nil {
+		return new(Goid)
+	}
+	if len(*ghosts) == cap(*ghosts) {
+		*ghosts = make([]Goid, 0, max(16, 2*cap(*ghosts)))
+	}
+	*ghosts = append(*ghosts, Goid{})
+	return &(*ghosts)[len(*ghosts)-1]
+}
+
+// squared distance between 2 goids, the short way round in wrap mode
+func (sp space) distanceSq(a, b *Goid) float64 {
+	pos := sp.seenFrom(a.Pos, b.Pos)
+	return sp.metric.distanceSq(a.pos3(), Vec3{pos.X, pos.Y, b.Z})
+}
+
+// bruteForce looks at every goid for every lookup, which is the reference
+// the faster indexes have to agree with
+type bruteForce struct {
+	space
+	goids []*Goid
+}
+
+func (b *bruteForce) nearestNeighbours(dst []Goid, g *Goid, k int, keep func(*Goid) bool) []Goid {
+	sc := b.seeAll(g)
+	defer scratchPool.Put(sc)
+	neighbours, _ := selectNearest(dst, g, sc.candidates, k, keep, b.metric)
+	return neighbours
+}
+
+func (b *bruteForce) neighboursWithin(dst []Goid, g *Goid, radius float64, keep func(*Goid) bool) (neighbours []Goid) {
+	neighbours = dst
+	sc := b.seeAll(g)
+	defer scratchPool.Put(sc)
+	for _, n := range sc.candidates {
+		if n != g && b.metric.distanceSq(g.pos3(), n.pos3()) <= radius*radius && (keep == nil || keep(n)) {
+			neighbours = append(neighbours, *n)
+		}
+	}
+	return
+}
+
+// a scratch with every goid as g sees it in its candidates, to be put back
+// in the pool once they've been looked at
+func (b *bruteForce) seeAll(g *Goid) *scratch {
+	sc := scratchPool.Get().(*scratch)
+	sc.ghosts = sc.ghosts[:0]
+	sc.candidates = b.appendSeen(sc.candidates[:0], &sc.ghosts, g, b.goids)
+	return sc
+}
+
+func (b *bruteForce) within(g *Goid, radius float64) (goids []*Goid) {
+	for _, n := range b.goids {
+		if n != g && b.distanceSq(g, n) <= radius*radius {
+			goids = append(goids, n)
+		}
+	}
+	return
+}
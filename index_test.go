@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// the squared distances from g to each of its neighbours, which are where g
+// sees them
+func distancesSq(g *Goid, neighbours []Goid, metric Metric) []float64 {
+	d := make([]float64, len(neighbours))
+	for i := range neighbours {
+		d[i] = metric.distanceSq(g.pos3(), neighbours[i].pos3())
+	}
+	return d
+}
+
+// whether two lists of distances are the same, but for rounding from
+// working out positions across an edge by different ways
+func sameDistances(a, b []float64) bool {
+	return slices.EqualFunc(a, b, func(x, y float64) bool { return math.Abs(x-y) <= 1e-9*math.Max(1, y) })
+}
+
+// the IDs of the neighbours, in order
+func neighbourIDs(neighbours []Goid) []int {
+	ids := make([]int, len(neighbours))
+	for i, n := range neighbours {
+		ids[i] = n.ID
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// every index finds the same neighbours as checking every pair, for random
+// flocks of random sizes from a seeded generator, in every kind of space
+func TestIndexesAgree(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	// only the odd ones, to see the indexes all filter the same way
+	odd := func(n *Goid) bool { return n.ID%2 == 1 }
+	for _, metric := range []Metric{Euclidean, Manhattan, Chebyshev} {
+		for _, boundary := range []BoundaryMode{Wrap, Bounce} {
+			for _, depth := range []int{0, 200} {
+				for trial := 0; trial < 5; trial++ {
+					cfg := DefaultConfig()
+					cfg.Population = 2 + rng.Intn(300)
+					cfg.Metric, cfg.Boundary, cfg.Depth = metric, boundary, depth
+					cfg.Seed = rng.Int63()
+					// sometimes bunched up, so some cells are crowded and others empty
+					cfg.Spawn = SpawnPattern(rng.Intn(len(spawnPatterns)))
+					k := 1 + rng.Intn(cfg.Population+2)
+					radius := 10 + 200*rng.Float64()
+					name := fmt.Sprintf("%v/%v/depth=%d/seed=%d", metric, boundary, depth, cfg.Seed)
+					sim := NewSimulation(cfg)
+					sim.Config.NeighbourIndex = BruteIndex
+					brute := sim.index()
+					for _, ix := range []NeighbourIndex{GridIndex, KDTreeIndex} {
+						sim.Config.NeighbourIndex = ix
+						index := sim.index()
+						for _, g := range sim.Goids[:min(40, len(sim.Goids))] {
+							for _, keep := range []func(*Goid) bool{nil, odd} {
+								want := distancesSq(g, brute.nearestNeighbours(nil, g, k, keep), metric)
+								if got := distancesSq(g, index.nearestNeighbours(nil, g, k, keep), metric); !sameDistances(got, want) {
+									t.Fatalf("%s: the %v found the %d nearest to goid %d at %v, want %v", name, ix, k, g.ID, got, want)
+								}
+								want2 := neighbourIDs(brute.neighboursWithin(nil, g, radius, keep))
+								if got := neighbourIDs(index.neighboursWithin(nil, g, radius, keep)); !slices.Equal(got, want2) {
+									t.Fatalf("%s: the %v found goids %v within %g of goid %d, want %v", name, ix, got, radius, g.ID, want2)
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestWrapAcrossEdges(t *testing.T) {
+	const width, height = 800, 600
+	left := &Goid{ID: 1, Pos: Vec2{2, 300}}
+	right := &Goid{ID: 2, Pos: Vec2{798, 300}}
+	middle := &Goid{ID: 3, Pos: Vec2{400, 300}}
+	goids := []*Goid{left, right, middle}
+	for _, wrap := range []bool{true, false} {
+		sp := space{wrap: wrap, width: width, height: height}
+		want, nearest := 4.0, right.ID
+		if !wrap {
+			want, nearest = 796, middle.ID
+		}
+		if d := sp.distanceSq(left, right); d != want*want {
+			t.Errorf("wrap %v: goids on opposite edges are %g apart, want %g", wrap, d, want*want)
+		}
+		indexes := map[string]neighbourIndex{
+			"brute":  &bruteForce{space: sp, goids: goids},
+			"grid":   newGrid(goids, 50, width, height, wrap, nil),
+			"kdtree": newKDTree(goids, sp),
+		}
+		for name, ix := range indexes {
+			if got := ix.nearestNeighbours(nil, left, 1, nil); len(got) != 1 || got[0].ID != nearest {
+				t.Errorf("wrap %v: %s found %v as the nearest to the goid on the left edge, want goid %d", wrap, name, got, nearest)
+			}
+			within := ix.neighboursWithin(nil, left, 10, nil)
+			if wrap && (len(within) != 1 || within[0].ID != right.ID) {
+				t.Errorf("wrap %v: %s found %v within 10 of the goid on the left edge, want goid %d", wrap, name, within, right.ID)
+			}
+			if !wrap && len(within) != 0 {
+				t.Errorf("wrap %v: %s found %v within 10 of the goid on the left edge, want none", wrap, name, within)
+			}
+		}
+	}
+}
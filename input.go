@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// how hard the mouse cursor pulls the flock in, or pushes it away while a
+// button is held
+const cursorStrength = 3.0
+
+// terminalInput reads the keyboard and mouse from a terminal in raw mode
+type terminalInput struct {
+	keys    chan byte // key presses, other than Ctrl-C
+	mu      sync.Mutex
+	col     int  // where the mouse was last seen, in 1-based terminal cells
+	row     int  // 0 until the mouse has moved
+	pressed bool // whether a mouse button is held
+}
+
+// put the terminal into raw mode and start reading from it. Raw mode
+// swallows Ctrl-C, so interrupt is called for it instead. The terminal is
+// put back how it was by restore.
+func startInput(f *os.File, interrupt func()) (in *terminalInput, restore func(), err error) {
+	state, err := term.MakeRaw(int(f.Fd()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading the terminal: %v", err)
+	}
+	in = &terminalInput{keys: make(chan byte, 16)}
+	go in.read(bufio.NewReader(f), interrupt)
+	return in, func() { term.Restore(int(f.Fd()), state) }, nil
+}
+
+// turn on reporting of every mouse move and button, in SGR form
+func enableMouse(w io.Writer) {
+	fmt.Fprint(w, "\x1b[?1003h\x1b[?1006h")
+}
+
+func disableMouse(w io.Writer) {
+	fmt.Fprint(w, "\x1b[?1003l\x1b[?1006l")
+}
+
+// read key presses and mouse reports until the input runs out
+func (in *terminalInput) read(r *bufio.Reader, interrupt func()) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		switch b {
+		case 3: // Ctrl-C
+			interrupt()
+		case 0x1b:
+			in.readEscape(r)
+		default:
+			// if nobody's keeping up, drop keys rather than block
+			select {
+			case in.keys <- b:
+			default:
+			}
+		}
+	}
+}
+
+// read the rest of an escape sequence, keeping track of the mouse if it's an
+// SGR mouse report like \x1b[<0;12;5M, which is button 0 pressed at column
+// 12, row 5, or ending in m when it's released
+func (in *terminalInput) readEscape(r *bufio.Reader) {
+	if b, _ := r.ReadByte(); b != '[' {
+		return
+	}
+	if b, _ := r.ReadByte(); b != '<' {
+		return
+	}
+	var report strings.Builder
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		if b == 'M' || b == 'm' {
+			in.mouseReport(report.String(), b == 'M')
+			return
+		}
+		report.WriteByte(b)
+	}
+}
+
+func (in *terminalInput) mouseReport(report string, down bool) {
+	fields := strings.Split(report, ";")
+	if len(fields) != 3 {
+		return
+	}
+	button, err1 := strconv.Atoi(fields[0])
+	col, err2 := strconv.Atoi(fields[1])
+	row, err3 := strconv.Atoi(fields[2])
+	if err1 != nil || err2 != nil || err3 != nil {
+		return
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.col, in.row = col, row
+	// the low 2 bits are the button, with 3 for none when just moving
+	in.pressed = down && button&3 != 3
+}
+
+// an attractor at the mouse cursor, pulling the flock in or pushing it away
+// while a button is held, or nil if the mouse hasn't been seen. Frames are
+// drawn from the second row, under the status line, down to just above the
+// bottom row, filling the width of the terminal, so the cursor is mapped
+// into the window by where it is in that area.
+func (in *terminalInput) cursor(cfg Config) *Attractor {
+	in.mu.Lock()
+	col, row, pressed := in.col, in.row, in.pressed
+	in.mu.Unlock()
+	if row == 0 {
+		return nil
+	}
+	cols, rows := terminalSize()
+	a := &Attractor{
+		Pos: Vec2{
+			float64(col-1) / float64(max(1, cols-1)) * float64(cfg.Width),
+			float64(row-2) / float64(max(1, rows-3)) * float64(cfg.Height),
+		},
+		Strength: cursorStrength,
+	}
+	if pressed {
+		a.Strength = -cursorStrength
+	}
+	return a
+}
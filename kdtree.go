@@ -0,0 +1,179 @@
+package main
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// kdTree is a k-d tree of goids, split on whichever of x, y and z the goids
+// under each node are most spread out along, so a flat flock is never split
+// on depth. It's rebuilt every step like the grid, but copes better with
+// goids bunched into a few tight clusters, where the grid ends up with a
+// handful of crowded cells.
+type kdTree struct {
+	space
+	nodes []kdNode
+	root  int
+}
+
+type kdNode struct {
+	goid        *Goid
+	left, right int  // indexes of the children in the tree's nodes, -1 for none
+	min, max    Vec3 // the box around every goid at or under this node
+}
+
+func newKDTree(goids []*Goid, sp space) *kdTree {
+	t := &kdTree{space: sp, nodes: make([]kdNode, 0, len(goids))}
+	// sorted in place while building, so it mustn't be the flock's slice
+	t.root = t.build(append([]*Goid(nil), goids...))
+	return t
+}
+
+// add a subtree for the goids, returning the index of its root
+func (t *kdTree) build(goids []*Goid) int {
+	if len(goids) == 0 {
+		return -1
+	}
+	lo, hi := goids[0].pos3(), goids[0].pos3()
+	for _, g := range goids[1:] {
+		p := g.pos3()
+		lo = Vec3{math.Min(lo.X, p.X), math.Min(lo.Y, p.Y), math.Min(lo.Z, p.Z)}
+		hi = Vec3{math.Max(hi.X, p.X), math.Max(hi.Y, p.Y), math.Max(hi.Z, p.Z)}
+	}
+	spread := hi.Sub(lo)
+	axis := 0
+	if spread.Y > spread.X {
+		axis = 1
+	}
+	if spread.Z > math.Max(spread.X, spread.Y) {
+		axis = 2
+	}
+	slices.SortFunc(goids, func(a, b *Goid) int { return cmp.Compare(coord(a.pos3(), axis), coord(b.pos3(), axis)) })
+	mid := len(goids) / 2
+	i := len(t.nodes)
+	t.nodes = append(t.nodes, kdNode{goid: goids[mid], min: lo, max: hi})
+	left := t.build(goids[:mid])
+	right := t.build(goids[mid+1:])
+	t.nodes[i].left, t.nodes[i].right = left, right
+	return i
+}
+
+// x, y or z
+func coord(v Vec3, axis int) float64 {
+	switch axis {
+	case 0:
+		return v.X
+	case 1:
+		return v.Y
+	}
+	return v.Z
+}
+
+// the squared distance from p to the nearest point of a node's box, the
+// short way round in wrap mode. That's the gap along each axis, put together
+// by the metric.
+func (t *kdTree) boxDistanceSq(n *kdNode, p Vec3) float64 {
+	var gaps [3]float64
+	for axis := 0; axis < 3; axis++ {
+		v, lo, hi := coord(p, axis), coord(n.min, axis), coord(n.max, axis)
+		var gap float64
+		if t.wrap && axis < 2 {
+			size := t.width
+			if axis == 1 {
+				size = t.height
+			}
+			// how far past lo p is going forwards round the loop, which
+			// is inside the box up to its length and otherwise short of
+			// it one way or the other
+			if length := hi - lo; length < size {
+				if past := math.Mod(math.Mod(v-lo, size)+size, size); past > length {
+					gap = math.Min(past-length, size-past)
+				}
+			}
+		} else if v < lo {
+			gap = lo - v
+		} else if v > hi {
+			gap = v - hi
+		}
+		gaps[axis] = gap
+	}
+	return t.metric.lengthSq(Vec3{gaps[0], gaps[1], gaps[2]})
+}
+
+// call f with each goid other than g in the subtrees whose boxes come within
+// the square root of limit of g, and the position g sees it at. limit is
+// asked again before each subtree, so it can shrink as the search goes on.
+func (t *kdTree) search(i int, g *Goid, limit func() float64, f func(n *Goid, pos Vec2)) {
+	if i < 0 {
+		return
+	}
+	node := &t.nodes[i]
+	p := g.pos3()
+	if t.boxDistanceSq(node, p) > limit() {
+		return
+	}
+	if node.goid != g {
+		f(node.goid, t.seenFrom(g.Pos, node.goid.Pos))
+	}
+	// the nearer child first, so the limit shrinks faster in a k nearest
+	// search and more of the further one can be skipped
+	near, far := node.left, node.right
+	if far >= 0 && (near < 0 || t.boxDistanceSq(&t.nodes[far], p) < t.boxDistanceSq(&t.nodes[near], p)) {
+		near, far = far, near
+	}
+	t.search(near, g, limit, f)
+	t.search(far, g, limit, f)
+}
+
+func (t *kdTree) nearestNeighbours(dst []Goid, g *Goid, k int, keep func(*Goid) bool) []Goid {
+	if k <= 0 {
+		return dst
+	}
+	sc := scratchPool.Get().(*scratch)
+	defer scratchPool.Put(sc)
+	h := &sc.nearest
+	*h = (*h)[:0]
+	limit := func() float64 {
+		if len(*h) < k {
+			return math.Inf(1)
+		}
+		return (*h)[0].distSq
+	}
+	t.search(t.root, g, limit, func(n *Goid, pos Vec2) {
+		seen := sc.see(n, pos)
+		if keep != nil && !keep(seen) {
+			return
+		}
+		h.offer(neighbour{*seen, t.metric.distanceSq(g.pos3(), seen.pos3())}, k)
+	})
+	return h.popNearest(dst)
+}
+
+func (t *kdTree) neighboursWithin(dst []Goid, g *Goid, radius float64, keep func(*Goid) bool) (neighbours []Goid) {
+	neighbours = dst
+	sc := scratchPool.Get().(*scratch)
+	defer scratchPool.Put(sc)
+	t.eachWithin(g, radius, func(n *Goid, pos Vec2) {
+		if seen := sc.see(n, pos); keep == nil || keep(seen) {
+			neighbours = append(neighbours, *seen)
+		}
+	})
+	return
+}
+
+func (t *kdTree) within(g *Goid, radius float64) (goids []*Goid) {
+	t.eachWithin(g, radius, func(n *Goid, _ Vec2) { goids = append(goids, n) })
+	return
+}
+
+// call f with each goid within radius of g, not including g, and the
+// position g sees it at
+func (t *kdTree) eachWithin(g *Goid, radius float64, f func(n *Goid, pos Vec2)) {
+	rSq := radius * radius
+	t.search(t.root, g, func() float64 { return rSq }, func(n *Goid, pos Vec2) {
+		if t.metric.distanceSq(g.pos3(), Vec3{pos.X, pos.Y, n.Z}) <= rSq {
+			f(n, pos)
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestKDTreeMatchesBrute(t *testing.T) {
+	for _, spawn := range []SpawnPattern{RandomSpawn, GroupsSpawn} {
+		for _, boundary := range []BoundaryMode{Wrap, Bounce} {
+			cfg := DefaultConfig()
+			cfg.Population = 500
+			cfg.Spawn = spawn
+			cfg.Boundary = boundary
+			cfg.Seed = 3
+			sim := NewSimulation(cfg)
+			sim.Config.NeighbourIndex = BruteIndex
+			brute := sim.index()
+			sim.Config.NeighbourIndex = KDTreeIndex
+			kd := sim.index()
+			for _, g := range sim.Goids[:50] {
+				want := distancesSq(g, brute.nearestNeighbours(nil, g, cfg.Neighbours, nil), cfg.Metric)
+				if got := distancesSq(g, kd.nearestNeighbours(nil, g, cfg.Neighbours, nil), cfg.Metric); !sameDistances(got, want) {
+					t.Fatalf("spawn %v, %v: the k-d tree found neighbours of goid %d at %v, want %v", spawn, boundary, g.ID, got, want)
+				}
+				want2 := neighbourIDs(brute.neighboursWithin(nil, g, cfg.PerceptionRadius, nil))
+				if got := neighbourIDs(kd.neighboursWithin(nil, g, cfg.PerceptionRadius, nil)); !slices.Equal(got, want2) {
+					t.Fatalf("spawn %v, %v: the k-d tree found goids %v within range of goid %d, want %v", spawn, boundary, got, g.ID, want2)
+				}
+			}
+		}
+	}
+}
+
+// look up the nearest neighbours of every goid in a flock bunched up in 2
+// groups, with the k-d tree and with the grid
+func BenchmarkKDTreeClustered(b *testing.B) {
+	for _, n := range []int{1500, 10000} {
+		cfg := DefaultConfig()
+		cfg.Population = n
+		cfg.Spawn = GroupsSpawn
+		sim := NewSimulation(cfg)
+		for _, ix := range []NeighbourIndex{KDTreeIndex, GridIndex} {
+			sim.Config.NeighbourIndex = ix
+			b.Run(fmt.Sprintf("%v/n=%d", ix, n), func(b *testing.B) {
+				var dst []Goid
+				for i := 0; i < b.N; i++ {
+					index := sim.index()
+					for _, g := range sim.Goids {
+						dst = index.nearestNeighbours(dst[:0], g, cfg.Neighbours, nil)
+					}
+				}
+			})
+		}
+	}
+}
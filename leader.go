@@ -0,0 +1,59 @@
+package main
+
+import (
+	"image/color"
+	"math"
+)
+
+// LeaderPath is the shape of the path leader goids fly round
+type LeaderPath int
+
+const (
+	Circle      LeaderPath = iota // round the middle of the window
+	FigureEight                   // a figure of eight across the middle of the window
+)
+
+var leaderPaths = []string{"circle", "figure8"}
+
+func (p LeaderPath) String() string { return enumName(leaderPaths, int(p)) }
+
+// MarshalText lets the path be used as a flag and in JSON by name
+func (p LeaderPath) MarshalText() ([]byte, error) { return []byte(p.String()), nil }
+
+// UnmarshalText parses the name of a path
+func (p *LeaderPath) UnmarshalText(text []byte) error {
+	return parseEnum(leaderPaths, text, "leader path", (*int)(p))
+}
+
+var leaderColor = color.RGBA{255, 255, 255, 255}
+
+// make the first of the goids leaders, spread out evenly along the path, and
+// drawn bigger so it's clear who's leading
+func makeLeaders(cfg Config, goids []*Goid) {
+	for i := 0; i < cfg.Leaders && i < len(goids); i++ {
+		g := goids[i]
+		g.Leader = true
+		g.LeaderPhase = float64(i) / float64(cfg.Leaders)
+		g.R = cfg.GoidSize * 2
+		g.Pos = leaderPosition(cfg, g, 0)
+	}
+}
+
+// where a leader is on its path at time t, in steps
+func leaderPosition(cfg Config, g *Goid, t float64) Vec2 {
+	centre := Vec2{float64(cfg.Width) / 2, float64(cfg.Height) / 2}
+	angle := 2 * math.Pi * (t/float64(cfg.LeaderPeriod) + g.LeaderPhase)
+	r := cfg.LeaderRadius
+	if cfg.LeaderPath == FigureEight {
+		return centre.Add(Vec2{r * math.Sin(angle), r * math.Sin(angle) * math.Cos(angle)})
+	}
+	return centre.Add(Vec2{r * math.Cos(angle), r * math.Sin(angle)})
+}
+
+// move a leader along its path to where it should be at time t, ignoring
+// the flock, its velocity being how far that took it
+func followPath(cfg Config, g *Goid, t float64) {
+	pos := leaderPosition(cfg, g, t)
+	g.Vel = pos.Sub(g.Pos)
+	g.Pos = pos
+}
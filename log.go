@@ -0,0 +1,20 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+)
+
+// send logs to stderr, keeping stdout for the frames. Only warnings and
+// errors are shown normally, verbosity 1 adds what the run's doing and 2
+// how long each frame takes.
+func setupLogging(verbosity int) {
+	level := slog.LevelWarn
+	switch {
+	case verbosity >= 2:
+		level = slog.LevelDebug
+	case verbosity == 1:
+		level = slog.LevelInfo
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+}
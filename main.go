@@ -1,192 +1,385 @@
 package main
 
 import (
-	"bytes"
-	"encoding/base64"
+	"context"
 	"fmt"
 	"image"
-	"image/color"
-	"image/png"
-	"math"
-	"math/rand"
-	"sort"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/llgcode/draw2d/draw2dimg"
+	"golang.org/x/term"
 )
 
-// parameters
-var windowWidth, windowHeight = 800, 600
-var goidSize = 3
-var goidColor = color.RGBA{200, 200, 100, 255} // gray, 50% transparency
-var populationSize = 150
-var loops = 100
-var numNeighbours = 7
-var separationFactor = float64(goidSize * 5)
-var coherenceFactor = 8
+// how many frames in a row can fail to show before the run gives up
+const maxRenderFailures = 10
 
 func main() {
-	clearScreen()
-	hideCursor()
-
-	goids := make([]*Goid, 0)
-	for i := 0; i < populationSize; i++ {
-		g := createRandomGoid()
-		goids = append(goids, &g)
+	cfg, opts := parseFlags()
+	if err := run(cfg, opts); err != nil {
+		fatal(err)
 	}
+}
 
-	for i := 0; i < loops; i++ {
-		move(goids)
-		frame := draw(goids)
-		printImage(frame.SubImage(frame.Rect))
-		fmt.Printf("\nLoop: %d", i)
-
+// run the simulation as the flags say. Whatever goes wrong is returned
+// rather than exiting there and then, so the terminal is always put back the
+// way it was on the way out.
+func run(cfg Config, opts options) error {
+	sim := NewSimulation(cfg)
+	if opts.load != "" {
+		if err := loadSimulation(sim, opts.load); err != nil {
+			return err
+		}
 	}
-	showCursor()
-}
 
-// Goid represents a drawn goid
-type Goid struct {
-	X     int // position
-	Y     int
-	Vx    int // velocity
-	Vy    int
-	R     int // radius
-	Color color.Color
-}
+	// on stderr whatever the log level, it's what it takes to run it again
+	if err := printConfig(os.Stderr, sim.Config); err != nil {
+		return err
+	}
 
-func createRandomGoid() (g Goid) {
-	g = Goid{
-		X:     rand.Intn(windowWidth),
-		Y:     rand.Intn(windowHeight),
-		Vx:    rand.Intn(goidSize),
-		Vy:    rand.Intn(goidSize),
-		R:     goidSize,
-		Color: goidColor,
+	var replaying *replayReader
+	if opts.replay != "" {
+		f, err := os.Open(opts.replay)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if replaying, err = newReplayReader(f, opts.replay); err != nil {
+			return err
+		}
 	}
-	return
-}
 
-// find the nearest neighbours
-func (g *Goid) nearestNeighbours(goids []*Goid) (neighbours []Goid) {
-	neighbours = make([]Goid, len(goids))
-	for _, goid := range goids {
-		neighbours = append(neighbours, *goid)
+	// exporting to files or serving to browsers doesn't need a terminal at all
+	headless := opts.headless || opts.gif != "" || opts.frames != "" || opts.serve != ""
+	// and only the terminal and files need the flock drawn
+	drawing := !headless || opts.gif != "" || opts.frames != ""
+	var rec *gifRecorder
+	if opts.gif != "" {
+		rec = newGIFRecorder(opts.gifDelay, opts.gifLoop, opts.gifMax)
+	}
+	var csvRec *csvRecorder
+	if opts.csv != "" {
+		var err error
+		if csvRec, err = newCSVRecorder(opts.csv); err != nil {
+			return err
+		}
+	}
+	if opts.frames != "" {
+		if err := os.MkdirAll(opts.frames, 0755); err != nil {
+			return err
+		}
 	}
-	sort.SliceStable(neighbours, func(i, j int) bool {
-		return g.distance(neighbours[i]) < g.distance(neighbours[j])
-	})
-	return
-}
 
-// distance between 2 goids
-func (g *Goid) distance(n Goid) float64 {
-	x := g.X - n.X
-	y := g.Y - n.Y
-	return math.Sqrt(float64(x*x + y*y))
+	out := os.Stdout
+	var r renderer
+	if !headless {
+		var err error
+		if r, err = newRenderer(opts.terminal, opts.enc, sim.Config.BackgroundColor); err != nil {
+			return err
+		}
+		clearScreen(out)
+		hideCursor(out)
+		// put the cursor back however we leave, even on a panic
+		defer showCursor(out)
+	}
 
-}
+	// an interrupt stops the run cleanly rather than killing the program
+	// with the cursor still hidden
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-// move the goids with the 3 classic boid rules
-func move(goids []*Goid) {
-	for _, goid := range goids {
-		neighbours := goid.nearestNeighbours(goids)
-		separate(goid, neighbours)
-		align(goid, neighbours)
-		cohere(goid, neighbours)
+	// keys and the mouse need the terminal in raw mode
+	var input *terminalInput
+	var ctl *controls
+	if !headless && (opts.mouse || !opts.noKeys) && term.IsTerminal(int(os.Stdin.Fd())) {
+		var restore func()
+		var err error
+		if input, restore, err = startInput(os.Stdin, stop); err != nil {
+			return err
+		}
+		defer restore()
+		if !opts.noKeys {
+			ctl = &controls{history: newHistory(opts.history)}
+		}
+		if opts.mouse {
+			enableMouse(out)
+			defer disableMouse(out)
+		}
+	}
 
-		stayInWindow(goid)
+	var srv *server
+	if opts.serve != "" {
+		var err error
+		if srv, err = startServer(opts.serve, sim, stop, opts.anySite); err != nil {
+			return err
+		}
+		defer srv.shutdown()
+		fmt.Fprintf(os.Stderr, "watch at http://%s/\n", browseAddr(opts.serve))
 	}
-}
 
-// if goid goes out of the window frame it comes back on the other side
-func stayInWindow(goid *Goid) {
-	if goid.X < 0 {
-		goid.X = windowWidth + goid.X
-	} else if goid.X > windowWidth {
-		goid.X = windowWidth - goid.X
+	// pace the terminal and browsers so each frame stays up for its share of
+	// a second. Files are written as fast as possible, their timing is in
+	// the file.
+	var tick <-chan time.Time
+	if (!headless || srv != nil) && opts.fps > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(opts.fps))
+		defer ticker.Stop()
+		tick = ticker.C
 	}
-	if goid.Y < 0 {
-		goid.Y = windowHeight + goid.Y
-	} else if goid.Y > windowHeight {
-		goid.Y = windowHeight - goid.Y
+
+	// a resized terminal has to be cleared, and with -fit the window
+	// follows it
+	resized := make(chan os.Signal, 1)
+	if !headless {
+		notifyResize(resized)
 	}
-}
 
-// steer to avoid crowding local goids
-func separate(g *Goid, neighbours []Goid) {
-	x, y := 0, 0
-	for _, n := range neighbours[0:numNeighbours] {
-		if g.distance(n) < separationFactor {
-			x += g.X - n.X
-			y += g.Y - n.Y
+	// the number of frames in a row that have failed to show, and the error
+	// the run was stopped with, if it was
+	failures := 0
+	var runErr error
+	fail := func(err error) {
+		if runErr == nil {
+			runErr = err
 		}
+		stop()
+	}
+	showStatus := func(i int) {
+		if !opts.quiet {
+			printStatus(out, status(sim, ctl, i))
+		}
+	}
+	// a frame that doesn't make it only leaves a gap, but if none of them
+	// are there's no point carrying on
+	present := func(frame *image.RGBA, i int) {
+		if err := r.printImage(out, frame.SubImage(frame.Rect)); err != nil {
+			slog.Error("showing frame", "loop", i, "err", err)
+			if failures++; failures >= maxRenderFailures {
+				fail(fmt.Errorf("giving up after %d frames in a row failed to show: %v", failures, err))
+			}
+		} else {
+			failures = 0
+		}
+		showStatus(i)
+	}
+	// the last frame shown
+	var last *image.RGBA
+	show := func(i int) {
+		start := time.Now()
+		sim.tickFPS()
+		select {
+		case <-resized:
+			clearScreen(out)
+			if w, h, ok := terminalFit(); ok && opts.fit {
+				sim.Resize(w, h)
+			}
+		default:
+		}
+		// the mouse is only picked up between steps, so a step always sees
+		// the same cursor
+		if input != nil && opts.mouse {
+			sim.Cursor = input.cursor(sim.Config)
+		}
+		if csvRec != nil {
+			if err := csvRec.record(i, sim.Goids); err != nil {
+				fail(err)
+			}
+		}
+		var frame *image.RGBA
+		if drawing {
+			frame = draw(sim)
+		}
+		last = frame
+		if rec != nil {
+			rec.add(frame)
+			if rec.full() {
+				stop()
+			}
+		}
+		if opts.frames != "" {
+			if err := writeFrame(opts.frames, i+1, frame, opts.enc); err != nil {
+				fail(err)
+			}
+		}
+		if srv != nil {
+			srv.sync(sim)
+			srv.broadcast(sim, i)
+		}
+		if !headless {
+			present(frame, i)
+		}
+		slog.Debug("frame", "loop", i, "step", sim.stepTime, "show", time.Since(start))
+		if ctl != nil {
+			ctl.handle(ctx, sim, input.keys, func() { showStatus(i) }, func() { present(draw(sim), i) })
+		}
+		// wait out the rest of the frame, unless we're interrupted, in which
+		// case the loop sees the cancelled context and stops
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+			}
+		}
+	}
+	// hold a converged flock on screen with a note saying so, until a key
+	// says whether to carry on
+	freeze := func(n int) bool {
+		if !opts.freeze || input == nil || last == nil {
+			return false
+		}
+		// a copy, so the note doesn't end up in the trails
+		frozen := image.NewRGBA(last.Rect)
+		copy(frozen.Pix, last.Pix)
+		drawPanel(frozen, []string{
+			fmt.Sprintf("converged at frame %d", n),
+			fmt.Sprintf("polarization %.2f", polarization(sim.Goids)),
+			"space to carry on, any other key to quit",
+		}, true)
+		present(frozen, n-1)
+		select {
+		case k := <-input.keys:
+			return k == ' '
+		case <-ctx.Done():
+			return false
+		}
+	}
+	// there's no point running before there's anyone to watch
+	if srv != nil && !srv.waitForViewer(ctx) {
+		return nil
+	}
+	// profile just the run, not the setting up
+	var stopProfile func() error
+	if opts.cpuProf != "" {
+		var err error
+		if stopProfile, err = startCPUProfile(opts.cpuProf); err != nil {
+			return err
+		}
+	}
+	var replayErr error
+	if replaying != nil {
+		replayErr = replay(ctx, sim, replaying, show)
+	} else {
+		// a run carried on after freezing goes on until it runs out of
+		// loops, without stopping to converge again
+		loops, change := sim.Config.Loops, sim.Config.ConvergeChange
+		done := 0
+		sim.OnFrame = func(i int, _ []*Goid) { show(done + i) }
+		for {
+			n, err := sim.Run(ctx)
+			done += n
+			// a run that ends by itself without running every loop has
+			// converged
+			if err != nil || (sim.Config.Loops != 0 && n == sim.Config.Loops) {
+				break
+			}
+			if !freeze(done) {
+				fmt.Fprintf(os.Stderr, "\nconverged after %d frames\n", done)
+				break
+			}
+			sim.Config.ConvergeChange = 0
+			if sim.Config.Loops > 0 {
+				sim.Config.Loops -= n
+			}
+		}
+		sim.Config.Loops, sim.Config.ConvergeChange = loops, change
 	}
-	g.Vx = x
-	g.Vy = y
-	g.X += x
-	g.Y += y
-}
 
-// steer towards the average heading of local goids
-func align(g *Goid, neighbours []Goid) {
-	x, y := 0, 0
-	for _, n := range neighbours[0:numNeighbours] {
-		x += n.Vx
-		y += n.Vy
-	}
-	dx, dy := x/numNeighbours, y/numNeighbours
-	g.Vx += dx
-	g.Vy += dy
-	g.X += dx
-	g.Y += dy
-}
+	if stopProfile != nil {
+		if err := stopProfile(); err != nil {
+			return err
+		}
+	}
+	if opts.memProf != "" {
+		if err := writeMemProfile(opts.memProf); err != nil {
+			return err
+		}
+	}
 
-// steer to move toward the average position of local goids
-func cohere(g *Goid, neighbours []Goid) {
-	x, y := 0, 0
-	for _, n := range neighbours[0:numNeighbours] {
-		x += n.X
-		y += n.Y
-	}
-	dx, dy := ((x/numNeighbours)-g.X)/coherenceFactor, ((y/numNeighbours)-g.Y)/coherenceFactor
-	g.Vx += dx
-	g.Vy += dy
-	g.X += dx
-	g.Y += dy
+	// an interrupted run still gets here, so the recording is complete up
+	// to the last frame
+	if csvRec != nil {
+		if err := csvRec.close(); err != nil {
+			return err
+		}
+	}
+	if rec != nil {
+		if err := rec.save(opts.gif); err != nil {
+			return err
+		}
+	}
+	if opts.svg != "" {
+		if err := saveSVG(sim, opts.svg); err != nil {
+			return err
+		}
+	}
+	if opts.save != "" {
+		if err := saveSimulation(sim, opts.save); err != nil {
+			return err
+		}
+	}
+	if opts.headless && sim.Config.ShowStats {
+		printStats(out, sim.Stats())
+	}
+	if runErr != nil {
+		return runErr
+	}
+	if replayErr != nil && replayErr != ctx.Err() {
+		return replayErr
+	}
+	return nil
 }
 
-// draw the goids
-func draw(goids []*Goid) *image.RGBA {
-	dest := image.NewRGBA(image.Rect(0, 0, windowWidth, windowHeight))
-	gc := draw2dimg.NewGraphicContext(dest)
-	for _, goid := range goids {
-		gc.SetFillColor(goid.Color)
-		gc.MoveTo(float64(goid.X), float64(goid.Y))
-		gc.ArcTo(float64(goid.X), float64(goid.Y), float64(goid.R), float64(goid.R), 0, -math.Pi*2)
-		gc.LineTo(float64(goid.X-goid.Vx), float64(goid.Y-goid.Vy))
-		gc.Close()
-		gc.Fill()
-	}
-	return dest
+// save the simulation to a JSON file
+func saveSimulation(sim *Simulation, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = sim.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
 
-// ANSI escape sequence codes to perform action on terminal
-func hideCursor() {
-	fmt.Print("\033[?25l")
+// load the simulation from a JSON file written by saveSimulation
+func loadSimulation(sim *Simulation, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return sim.Load(f)
 }
 
-func showCursor() {
-	fmt.Print("\x1b[?25h\n")
+// the line above the frame saying how the run is going
+func status(sim *Simulation, ctl *controls, i int) string {
+	s := fmt.Sprintf("Loop: %d", i)
+	if sim.Config.EatRadius > 0 {
+		c := sim.Census
+		s += fmt.Sprintf(" prey: %d (+%d -%d) predators: %d (+%d -%d)    ",
+			len(sim.Goids), c.PreyBorn, c.PreyEaten, len(sim.Predators), c.PredatorsBorn, c.PredatorsStarved)
+	}
+	if ctl != nil {
+		s += ctl.status(sim.Config)
+	}
+	return s
 }
 
-func clearScreen() {
-	fmt.Print("\x1b[2J")
+// where to point a browser at for a server listening on addr, which might
+// leave out the host
+func browseAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "localhost" + addr
+	}
+	return addr
 }
 
-// this only works for iTerm!
-func printImage(img image.Image) {
-	var buf bytes.Buffer
-	png.Encode(&buf, img)
-	imgBase64Str := base64.StdEncoding.EncodeToString(buf.Bytes())
-	fmt.Printf("\x1b[2;0H\x1b]1337;File=inline=1:%s\a", imgBase64Str)
+// log the error and exit
+func fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
 }
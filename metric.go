@@ -0,0 +1,43 @@
+package main
+
+import "math"
+
+// Metric is how the distance between goids is measured, for ranking
+// neighbours and checking them against the perception and separation radii
+type Metric int
+
+const (
+	Euclidean Metric = iota // the straight line distance
+	Manhattan               // the sum of the distances along each axis
+	Chebyshev               // the longest of the distances along each axis
+)
+
+var metricNames = []string{"euclidean", "manhattan", "chebyshev"}
+
+func (m Metric) String() string { return enumName(metricNames, int(m)) }
+
+// MarshalText lets the metric be used as a flag and in JSON by name
+func (m Metric) MarshalText() ([]byte, error) { return []byte(m.String()), nil }
+
+// UnmarshalText parses the name of a metric
+func (m *Metric) UnmarshalText(text []byte) error {
+	return parseEnum(metricNames, text, "metric", (*int)(m))
+}
+
+// the square of the length of d. It's squared so it can be compared with a
+// squared radius like the straight line distance always has been, which
+// saves a square root there, and the others don't need one either.
+func (m Metric) lengthSq(d Vec3) float64 {
+	switch m {
+	case Manhattan:
+		l := math.Abs(d.X) + math.Abs(d.Y) + math.Abs(d.Z)
+		return l * l
+	case Chebyshev:
+		l := math.Max(math.Abs(d.X), math.Max(math.Abs(d.Y), math.Abs(d.Z)))
+		return l * l
+	}
+	return d.Dot(d)
+}
+
+// the squared distance from a to b
+func (m Metric) distanceSq(a, b Vec3) float64 { return m.lengthSq(b.Sub(a)) }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestMetricDistances(t *testing.T) {
+	a, b := Vec3{1, 2, 3}, Vec3{4, -2, 15}
+	tests := []struct {
+		metric Metric
+		want   float64
+	}{
+		{Euclidean, 13},
+		{Manhattan, 19},
+		{Chebyshev, 12},
+	}
+	for _, tt := range tests {
+		if got := math.Sqrt(tt.metric.distanceSq(a, b)); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%v distance from %v to %v = %g, want %g", tt.metric, a, b, got, tt.want)
+		}
+	}
+}
+
+// each metric is one: never negative, zero only from a point to itself, the
+// same both ways and never shorter going straight than going by way of
+// somewhere else
+func TestMetricProperties(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	point := func() Vec3 { return Vec3{rng.Float64()*1600 - 800, rng.Float64()*1200 - 600, rng.Float64()*400 - 200} }
+	for _, m := range []Metric{Euclidean, Manhattan, Chebyshev} {
+		d := func(a, b Vec3) float64 { return math.Sqrt(m.distanceSq(a, b)) }
+		for i := 0; i < 1000; i++ {
+			a, b, c := point(), point(), point()
+			switch {
+			case d(a, a) != 0:
+				t.Fatalf("%v: %v is %g from itself", m, a, d(a, a))
+			case d(a, b) <= 0:
+				t.Fatalf("%v: %v and %v are %g apart", m, a, b, d(a, b))
+			case d(a, b) != d(b, a):
+				t.Fatalf("%v: %v and %v are %g apart one way and %g the other", m, a, b, d(a, b), d(b, a))
+			case d(a, c) > d(a, b)+d(b, c)+1e-9:
+				t.Fatalf("%v: %v to %v is %g, further than %g by way of %v", m, a, c, d(a, c), d(a, b)+d(b, c), b)
+			}
+		}
+	}
+	// and they're in the usual order for any 2 points
+	for i := 0; i < 1000; i++ {
+		a, b := point(), point()
+		if e, l1, inf := Euclidean.distanceSq(a, b), Manhattan.distanceSq(a, b), Chebyshev.distanceSq(a, b); inf > e || e > l1 {
+			t.Fatalf("%v to %v: chebyshev %g, euclidean %g, manhattan %g squared", a, b, inf, e, l1)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics are the flock's Stats for Prometheus to scrape, updated every frame
+type metrics struct {
+	registry     *prometheus.Registry
+	fps          prometheus.Gauge
+	population   prometheus.Gauge
+	averageSpeed prometheus.Gauge
+	polarization prometheus.Gauge
+	stepTime     prometheus.Histogram
+}
+
+func newMetrics() *metrics {
+	gauge := func(name, help string) prometheus.Gauge {
+		return prometheus.NewGauge(prometheus.GaugeOpts{Namespace: "goids", Name: name, Help: help})
+	}
+	m := &metrics{
+		registry:     prometheus.NewRegistry(),
+		fps:          gauge("frames_per_second", "Frames drawn per second, smoothed."),
+		population:   gauge("population", "Number of goids in the flock."),
+		averageSpeed: gauge("average_speed", "Average speed of the goids, in pixels per step."),
+		polarization: gauge("polarization", "Length of the flock's average heading, 1 when all goids fly the same way."),
+		// from 0.1ms to about 1.6s
+		stepTime: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "goids",
+			Name:      "step_duration_seconds",
+			Help:      "Time taken to work out each step.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 15),
+		}),
+	}
+	m.registry.MustRegister(m.fps, m.population, m.averageSpeed, m.polarization, m.stepTime)
+	return m
+}
+
+// take in the stats for a frame
+func (m *metrics) update(st Stats) {
+	m.fps.Set(st.FPS)
+	m.population.Set(float64(st.Population))
+	m.averageSpeed.Set(st.AverageSpeed)
+	m.polarization.Set(st.Polarization)
+	m.stepTime.Observe(st.StepTime.Seconds())
+}
+
+// the handler for Prometheus to scrape
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+	"sync"
+)
+
+// scratch is space to work out a goid's neighbours in. It's borrowed from
+// scratchPool and given back once done with, so a step doesn't allocate it
+// afresh for every goid, and each goroutine in a parallel step ends up with
+// its own.
+type scratch struct {
+	neighbours []Goid
+	kin        []Goid        // neighbours of the same species, when there's more than one
+	nearest    neighbourHeap // the nearest found so far in a k nearest search
+	candidates []*Goid       // the goids a k nearest search picks from
+	ghosts     []Goid        // the candidates seen across an edge in wrap mode
+	seen       Goid          // a candidate where it's seen from, for keep to look at
+	view       view          // what the goid being moved can see
+	// view's filters, made once for the scratch rather than once a goid
+	sees, seesKin func(*Goid) bool
+}
+
+var scratchPool = sync.Pool{New: func() any {
+	sc := new(scratch)
+	sc.sees, sc.seesKin = sc.view.sees, sc.view.seesKin
+	return sc
+}}
+
+// view is what a goid can see, the goids inside its field of view
+type view struct {
+	g               *Goid
+	fov, cosHalfFOV float64
+}
+
+// look out from g with a field of view of fov degrees
+func (v *view) set(g *Goid, fov float64) {
+	v.g, v.fov, v.cosHalfFOV = g, fov, math.Cos(fov/2*math.Pi/180)
+}
+
+// whether n's in view
+func (v *view) sees(n *Goid) bool { return v.g.canSee(n, v.fov, v.cosHalfFOV) }
+
+// whether n's in view and of the same species
+func (v *view) seesKin(n *Goid) bool { return n.Species == v.g.Species && v.sees(n) }
+
+// a candidate neighbour and its squared distance from the goid doing the
+// looking, computed once when the candidate is considered
+type neighbour struct {
+	goid   Goid
+	distSq float64
+}
+
+// neighbourHeap is a max-heap on distance, so the furthest of the k nearest
+// goids found so far is always at the top and can be cheaply replaced
+type neighbourHeap []neighbour
+
+func (h neighbourHeap) Len() int            { return len(h) }
+func (h neighbourHeap) Less(i, j int) bool  { return h[i].distSq > h[j].distSq }
+func (h neighbourHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *neighbourHeap) Push(x interface{}) { *h = append(*h, x.(neighbour)) }
+func (h *neighbourHeap) Pop() interface{} {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+// select the k candidates nearest to g by the metric that keep allows (all
+// of them if keep is nil), in ascending order of distance, without sorting
+// all of them, and append them to dst. kthSq is the squared distance of the
+// furthest one returned, or -1 if there were fewer than k candidates.
+func selectNearest(dst []Goid, g *Goid, candidates []*Goid, k int, keep func(*Goid) bool, metric Metric) (neighbours []Goid, kthSq float64) {
+	sc := scratchPool.Get().(*scratch)
+	defer scratchPool.Put(sc)
+	// the heap is worked on where it is in the scratch, as a pointer to a
+	// copy of it here would have the copy moved to the heap
+	h := &sc.nearest
+	*h = (*h)[:0]
+	for _, c := range candidates {
+		if c == g || (keep != nil && !keep(c)) {
+			continue
+		}
+		h.offer(neighbour{*c, metric.distanceSq(g.pos3(), c.pos3())}, k)
+	}
+	kthSq = -1
+	if len(*h) == k && k > 0 {
+		kthSq = (*h)[0].distSq
+	}
+	neighbours = h.popNearest(dst)
+	return
+}
+
+// keep n if it's one of the k nearest so far. This and popNearest go round
+// heap.Push and heap.Pop, which would allocate boxing each neighbour up.
+func (h *neighbourHeap) offer(n neighbour, k int) {
+	if len(*h) < k {
+		*h = append(*h, n)
+		heap.Fix(h, len(*h)-1)
+	} else if k > 0 && n.distSq < (*h)[0].distSq {
+		(*h)[0] = n
+		heap.Fix(h, 0)
+	}
+}
+
+// empty the heap onto the end of dst, nearest first
+func (h *neighbourHeap) popNearest(dst []Goid) []Goid {
+	start := len(dst)
+	dst = append(dst, make([]Goid, len(*h))...)
+	for i := len(dst) - 1; i >= start; i-- {
+		last := len(*h) - 1
+		dst[i] = (*h)[0].goid
+		(*h)[0] = (*h)[last]
+		*h = (*h)[:last]
+		if last > 0 {
+			heap.Fix(h, 0)
+		}
+	}
+	return dst
+}
+
+// a filter for the goids inside g's field of view of fov degrees
+func (g *Goid) inView(fov float64) func(*Goid) bool {
+	v := new(view)
+	v.set(g, fov)
+	return v.sees
+}
+
+// whether n is inside g's forward field of view. To save working it out for
+// every candidate, the cosine of half the field of view is passed in too.
+// A goid that's hardly moving has no real heading, so it sees all round.
+func (g *Goid) canSee(n *Goid, fov, cosHalfFOV float64) bool {
+	if fov >= 360 {
+		return true
+	}
+	speed := g.speed()
+	if speed < 1e-6 {
+		return true
+	}
+	d := n.pos3().Sub(g.pos3())
+	dist := d.Len()
+	if dist == 0 {
+		return true
+	}
+	return g.vel3().Dot(d)/(speed*dist) >= cosHalfFOV
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// the k nearest of the goids to g by sorting all of them, the way it was done
+// before selectNearest
+func sortedNearest(g *Goid, goids []*Goid, k int) []Goid {
+	var all []Goid
+	for _, n := range goids {
+		if n != g {
+			all = append(all, *n)
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool { return g.distance(all[i]) < g.distance(all[j]) })
+	return all[:min(k, len(all))]
+}
+
+func TestSelectNearest(t *testing.T) {
+	goids := randomGoids(200, 3)
+	for _, k := range []int{0, 1, 7, 199, 500} {
+		for _, g := range goids[:20] {
+			got, _ := selectNearest(nil, g, goids, k, nil, Euclidean)
+			want := sortedNearest(g, goids, k)
+			if len(got) != len(want) {
+				t.Fatalf("k=%d: got %d neighbours, want %d", k, len(got), len(want))
+			}
+			for i := range got {
+				if g.distanceSq(got[i]) != g.distanceSq(want[i]) {
+					t.Fatalf("k=%d: neighbour %d is %g away, want %g", k, i, g.distance(got[i]), g.distance(want[i]))
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkSelectNearest(b *testing.B) {
+	for _, n := range []int{150, 1500} {
+		goids := randomGoids(n, 1)
+		g := goids[0]
+		b.Run(fmt.Sprintf("select/n=%d", n), func(b *testing.B) {
+			var dst []Goid
+			for i := 0; i < b.N; i++ {
+				dst, _ = selectNearest(dst[:0], g, goids, 7, nil, Euclidean)
+			}
+		})
+		b.Run(fmt.Sprintf("sort/n=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				sortedNearest(g, goids, 7)
+			}
+		})
+	}
+}
+
+// sorting by distance, working out the square root twice in every comparison
+// as it used to, against working out each squared distance once up front
+func BenchmarkSortByDistance(b *testing.B) {
+	goids := randomGoids(1500, 1)
+	g := goids[0]
+	b.Run("sqrt", func(b *testing.B) {
+		sqrts := 0
+		all := make([]Goid, len(goids))
+		for i := 0; i < b.N; i++ {
+			for j, n := range goids {
+				all[j] = *n
+			}
+			sort.SliceStable(all, func(i, j int) bool {
+				sqrts += 2
+				return g.distance(all[i]) < g.distance(all[j])
+			})
+		}
+		b.ReportMetric(float64(sqrts)/float64(b.N), "sqrts/op")
+	})
+	b.Run("cached", func(b *testing.B) {
+		all := make([]neighbour, len(goids))
+		for i := 0; i < b.N; i++ {
+			for j, n := range goids {
+				all[j] = neighbour{*n, g.distanceSq(*n)}
+			}
+			sort.SliceStable(all, func(i, j int) bool { return all[i].distSq < all[j].distSq })
+		}
+		b.ReportMetric(0, "sqrts/op")
+	})
+}
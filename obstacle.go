@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"os"
+)
+
+const (
+	obstacleRange     = 50.0 // how far from an obstacle's edge goids start steering around it
+	obstacleAvoidance = 3.0  // how hard goids steer away right at the edge
+)
+
+var obstacleColor = color.RGBA{90, 90, 140, 255}
+
+// Obstacle is a static circle the flock has to flow around
+type Obstacle struct {
+	Pos    Vec2    `json:"pos"`
+	Radius float64 `json:"radius"`
+}
+
+// steer away from any obstacle the goid is close to and heading into, harder
+// the closer it is
+func avoidObstacles(g *Goid, obstacles []Obstacle) (steer Vec2) {
+	for _, o := range obstacles {
+		away := g.Pos.Sub(o.Pos)
+		gap := away.Len() - o.Radius
+		heading := g.Vel.X*away.X+g.Vel.Y*away.Y < 0
+		if gap < obstacleRange && heading {
+			proximity := 1 - gap/obstacleRange
+			steer = steer.Add(away.Normalize().Scale(obstacleAvoidance * proximity))
+		}
+	}
+	return
+}
+
+// push a goid that's ended up inside an obstacle back out to its edge, and
+// take away the part of its velocity heading in, so it slides around instead
+func stayOutOfObstacles(g *Goid, obstacles []Obstacle) {
+	for _, o := range obstacles {
+		away := g.Pos.Sub(o.Pos)
+		minDist := o.Radius + float64(g.R)
+		if away.Len() >= minDist {
+			continue
+		}
+		n := away.Normalize()
+		if n == (Vec2{}) {
+			n = Vec2{1, 0} // dead centre, any way out will do
+		}
+		g.Pos = o.Pos.Add(n.Scale(minDist))
+		if in := g.Vel.X*n.X + g.Vel.Y*n.Y; in < 0 {
+			g.Vel = g.Vel.Sub(n.Scale(in))
+		}
+	}
+}
+
+// read a JSON array of obstacles from a file, like
+// [{"pos": {"x": 400, "y": 300}, "radius": 60}]
+func loadObstacles(path string) (obstacles []Obstacle, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &obstacles); err != nil {
+		err = fmt.Errorf("reading obstacles from %s: %v", path, err)
+	}
+	return
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestAdvanceWrapsOutOfObstacles(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Boundary = Wrap
+	cfg.Obstacles = []Obstacle{{Pos: Vec2{10, 300}, Radius: 30}}
+	sim := NewSimulation(cfg)
+	// about to wrap over the right edge into the obstacle on the left
+	g := &Goid{Pos: Vec2{795, 300}, Vel: Vec2{10, 0}, R: cfg.GoidSize, Mass: 1}
+	sim.advance(g, cfg.window(), Vec3{}, cfg.MaxSpeed)
+	if d := g.Pos.Sub(cfg.Obstacles[0].Pos).Len(); d < cfg.Obstacles[0].Radius+float64(g.R) {
+		t.Errorf("goid wrapped to %v, %g inside the obstacle", g.Pos, cfg.Obstacles[0].Radius+float64(g.R)-d)
+	}
+}
+
+func TestValidateObstacleRadius(t *testing.T) {
+	for _, r := range []float64{0, -5} {
+		cfg := DefaultConfig()
+		cfg.Obstacles = []Obstacle{{Pos: Vec2{100, 100}, Radius: 40}, {Pos: Vec2{400, 300}, Radius: r}}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("an obstacle of radius %g is valid", r)
+		}
+	}
+}
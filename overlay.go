@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	imagedraw "image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+var (
+	statsColor      = color.RGBA{220, 220, 220, 255}
+	statsPanelColor = color.RGBA{0, 0, 0, 160}
+)
+
+// print the frame rate, loop, population, average speed and how aligned the
+// flock is in the top left corner, on a small dark panel so it reads over the
+// flock
+func drawStats(dest *image.RGBA, sim *Simulation) {
+	st := sim.Stats()
+	lines := []string{
+		fmt.Sprintf("fps   %.1f", sim.fps),
+		fmt.Sprintf("loop  %d", st.Frame-1),
+		fmt.Sprintf("goids %d", st.Population),
+		fmt.Sprintf("speed %.2f", st.AverageSpeed),
+		fmt.Sprintf("order %.2f", st.Polarization),
+	}
+	if len(sim.Predators) > 0 {
+		lines = append(lines, fmt.Sprintf("preds %d", len(sim.Predators)))
+	}
+	drawPanel(dest, lines, false)
+}
+
+// print the lines on a small dark panel, in the top left corner or in the
+// middle of the frame
+func drawPanel(dest *image.RGBA, lines []string, middle bool) {
+	face := basicfont.Face7x13
+	d := &font.Drawer{Dst: dest, Src: image.NewUniform(statsColor), Face: face}
+	width := 0
+	for _, l := range lines {
+		if w := d.MeasureString(l).Ceil(); w > width {
+			width = w
+		}
+	}
+	const pad = 4
+	panel := image.Rect(0, 0, width+2*pad, len(lines)*face.Height+2*pad)
+	if middle {
+		size := dest.Rect.Size().Sub(panel.Size())
+		panel = panel.Add(dest.Rect.Min.Add(size.Div(2)))
+	}
+	imagedraw.Draw(dest, panel, image.NewUniform(statsPanelColor), image.Point{}, imagedraw.Over)
+	for i, l := range lines {
+		d.Dot = fixed.P(panel.Min.X+pad, panel.Min.Y+pad+i*face.Height+face.Ascent)
+		d.DrawString(l)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"strings"
+)
+
+// Palette gives each goid its own color when it's made, so single goids can
+// be followed by eye. Without one, goids are the color of their species.
+type Palette struct {
+	Wheel  bool         // colors spread round the hue wheel
+	Colors []color.RGBA // or these, taken in turn
+}
+
+// MarshalText lets the palette be used as a flag and in JSON, as wheel or
+// a list of colors like #ff0000,#00ff00
+func (p Palette) MarshalText() ([]byte, error) {
+	if p.Wheel {
+		return []byte("wheel"), nil
+	}
+	names := make([]string, len(p.Colors))
+	for i, c := range p.Colors {
+		names[i] = hexColor(c)
+	}
+	return []byte(strings.Join(names, ",")), nil
+}
+
+// UnmarshalText parses wheel, a list of colors, or nothing for no palette
+func (p *Palette) UnmarshalText(text []byte) error {
+	*p = Palette{}
+	s := string(text)
+	switch s {
+	case "":
+		return nil
+	case "wheel":
+		p.Wheel = true
+		return nil
+	}
+	for _, name := range strings.Split(s, ",") {
+		c, err := parseHexColor(strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+		p.Colors = append(p.Colors, c)
+	}
+	return nil
+}
+
+// the color for the goid with the given ID, or otherwise if there's no
+// palette. Going by ID, a goid's color only depends on which one it is.
+func (p Palette) color(id int, otherwise color.RGBA) color.RGBA {
+	switch {
+	case p.Wheel:
+		// a golden angle apart, so goids made one after the other are
+		// never close in color however many there are
+		return hueColor(math.Mod(float64(id)*137.508, 360))
+	case len(p.Colors) > 0:
+		return p.Colors[id%len(p.Colors)]
+	}
+	return otherwise
+}
+
+// a bright color with the hue h, in degrees
+func hueColor(h float64) color.RGBA {
+	const s, v = 0.7, 1.0
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g = c, x
+	case h < 120:
+		r, g = x, c
+	case h < 180:
+		g, b = c, x
+	case h < 240:
+		g, b = x, c
+	case h < 300:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+	m := v - c
+	return color.RGBA{uint8(255 * (r + m)), uint8(255 * (g + m)), uint8(255 * (b + m)), 255}
+}
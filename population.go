@@ -0,0 +1,108 @@
+package main
+
+// Census counts the births and deaths in the last step, when predators eat
+type Census struct {
+	PreyBorn         int
+	PreyEaten        int
+	PredatorsBorn    int
+	PredatorsStarved int
+}
+
+// have the predator eat the nearest prey it has caught, if there is one
+// that no other predator has already eaten this step
+func (s *Simulation) eat(p *Predator, ix neighbourIndex, eaten map[*Goid]bool) {
+	var meal *Goid
+	for _, g := range ix.within(&p.Goid, s.Config.EatRadius) {
+		if !eaten[g] && (meal == nil || ix.distanceSq(&p.Goid, g) < ix.distanceSq(&p.Goid, meal)) {
+			meal = g
+		}
+	}
+	if meal != nil {
+		eaten[meal] = true
+		p.Energy += s.Config.EatEnergy
+	}
+}
+
+// rebuild the goid and predator slices after a step of eating: eaten prey
+// and starved predators are dropped, well fed predators split in two and
+// prey breed towards the configured population
+func (s *Simulation) updatePopulation(eaten map[*Goid]bool) {
+	cfg := s.Config
+	s.Census = Census{PreyEaten: len(eaten)}
+
+	goids := make([]*Goid, 0, len(s.Goids))
+	for _, g := range s.Goids {
+		if !eaten[g] {
+			goids = append(goids, g)
+		}
+	}
+	// breeding slows down as the flock fills back up to Population
+	rate := cfg.PreyBirthRate * (1 - float64(len(goids))/float64(cfg.Population))
+	for _, parent := range goids[:len(goids):len(goids)] {
+		if s.rng.Float64() < rate {
+			child := createRandomGoid(cfg, s.rng, s.newID())
+			child.Pos, child.Species, child.Color = parent.Pos, parent.Species, parent.Color
+			goids = append(goids, &child)
+			s.Census.PreyBorn++
+		}
+	}
+	s.Goids = goids
+
+	predators := make([]*Predator, 0, len(s.Predators))
+	for _, p := range s.Predators {
+		p.Energy--
+		if p.Energy <= 0 {
+			s.Census.PredatorsStarved++
+			continue
+		}
+		predators = append(predators, p)
+		if p.Energy >= 2*cfg.PredatorEnergy {
+			p.Energy /= 2
+			child := createRandomPredator(cfg, s.rng, s.newID())
+			child.Pos, child.Energy = p.Pos, p.Energy
+			predators = append(predators, child)
+			s.Census.PredatorsBorn++
+		}
+	}
+	s.Predators = predators
+}
+
+// replace goids that have outlived MaxAge with new ones of the same species
+// somewhere else, so the flock keeps its size. Leaders fly on forever.
+func (s *Simulation) respawnOld() {
+	species := s.Config.species()
+	for _, g := range s.Goids {
+		if g.Leader || g.Age <= s.Config.MaxAge {
+			continue
+		}
+		young := createRandomGoid(s.Config, s.rng, s.newID())
+		young.Species, young.Color = g.Species, s.Config.Palette.color(young.ID, species[g.Species].Color)
+		*g = young
+	}
+}
+
+// Add puts n more goids into the flock at random, spread between the
+// species, and raises Population to match. Call it between steps.
+func (s *Simulation) Add(n int) {
+	species := s.Config.species()
+	for i := 0; i < n; i++ {
+		g := createRandomGoid(s.Config, s.rng, s.newID())
+		if len(species) > 1 {
+			g.Species = s.rng.Intn(len(species))
+		}
+		g.Color = s.Config.Palette.color(g.ID, species[g.Species].Color)
+		s.Goids = append(s.Goids, &g)
+	}
+	s.Config.Population += n
+}
+
+// Remove takes the last n goids out of the flock, though never the very last
+// one, and lowers Population to match. Call it between steps.
+func (s *Simulation) Remove(n int) {
+	n = min(n, len(s.Goids)-1)
+	if n <= 0 {
+		return
+	}
+	s.Goids = s.Goids[:len(s.Goids)-n]
+	s.Config.Population = max(1, s.Config.Population-n)
+}
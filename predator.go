@@ -0,0 +1,63 @@
+package main
+
+import (
+	"image/color"
+	"math/rand"
+)
+
+// how quickly a predator turns towards its prey, as a fraction of the
+// difference between where it's going and where it wants to go
+const chaseFactor = 0.2
+
+var predatorColor = color.RGBA{220, 60, 60, 255}
+
+// Predator is a goid that doesn't flock, it chases the nearest prey instead
+type Predator struct {
+	Goid
+	Energy float64 // only used when predators eat, at 0 the predator starves
+}
+
+func createRandomPredator(cfg Config, rng *rand.Rand, id int) *Predator {
+	p := &Predator{Goid: createRandomGoid(cfg, rng, id), Energy: cfg.PredatorEnergy}
+	p.R = cfg.GoidSize * 2
+	p.Color = predatorColor
+	return p
+}
+
+// move each predator towards the goid nearest to it, and if predators eat,
+// have them eat any prey they catch
+func (s *Simulation) movePredators(ix neighbourIndex) {
+	cfg := s.Config
+	win := cfg.window()
+	var eaten map[*Goid]bool
+	if cfg.EatRadius > 0 {
+		eaten = make(map[*Goid]bool)
+	}
+	for _, p := range s.Predators {
+		var steer Vec3
+		if prey := ix.nearestNeighbours(nil, &p.Goid, 1, nil); len(prey) > 0 {
+			want := prey[0].pos3().Sub(p.pos3()).Normalize().Scale(cfg.PredatorSpeed)
+			steer = want.Sub(p.vel3()).Scale(chaseFactor)
+		}
+		steer = steer.Add(avoidObstacles(&p.Goid, cfg.Obstacles).Vec3())
+		s.advance(&p.Goid, win, steer, cfg.PredatorSpeed)
+		if eaten != nil {
+			s.eat(p, ix, eaten)
+		}
+	}
+	if eaten != nil {
+		s.updatePopulation(eaten)
+	}
+}
+
+// the direction to run in to get away from any predators within the danger
+// radius, closer ones counting for more, or the zero vector if none are
+func fleePredators(g *Goid, predators []*Predator, dangerRadius float64) (flee Vec2) {
+	for _, p := range predators {
+		away := g.Pos.Sub(p.Pos)
+		if d := away.Len(); d < dangerRadius {
+			flee = flee.Add(away.Normalize().Scale(1 - d/dangerRadius))
+		}
+	}
+	return flee.Normalize()
+}
@@ -0,0 +1,103 @@
+package main
+
+// Preset is a named set of flocking parameters to start from, for seeing
+// each of the different ways a flock can behave without having to find the
+// numbers
+type Preset int
+
+const (
+	FlockPreset Preset = iota
+	GasPreset
+	SwarmPreset
+	SchoolPreset
+	VortexPreset
+)
+
+var presets = []string{"flock", "gas", "swarm", "school", "vortex"}
+
+func (p Preset) String() string { return enumName(presets, int(p)) }
+
+// MarshalText lets the preset be used as a flag by name
+func (p Preset) MarshalText() ([]byte, error) { return []byte(p.String()), nil }
+
+// UnmarshalText parses the name of a preset
+func (p *Preset) UnmarshalText(text []byte) error {
+	return parseEnum(presets, text, "preset", (*int)(p))
+}
+
+// the config for the preset
+func (p Preset) Config() Config {
+	switch p {
+	case GasPreset:
+		return gasConfig()
+	case SwarmPreset:
+		return swarmConfig()
+	case SchoolPreset:
+		return schoolConfig()
+	case VortexPreset:
+		return vortexConfig()
+	}
+	return flockConfig()
+}
+
+// the classic flock, the defaults: loose groups that drift along together,
+// merging and splitting as they meet
+func flockConfig() Config { return DefaultConfig() }
+
+// separation and nothing else, so the goids spread themselves evenly over
+// the window and jostle about like the molecules of a gas, never forming
+// groups at all
+func gasConfig() Config {
+	cfg := DefaultConfig()
+	cfg.SeparationFactor = 40
+	cfg.SeparationWeight = 1
+	cfg.AlignmentWeight = 0
+	cfg.CohesionWeight = 0
+	cfg.WanderStrength = 1
+	cfg.MaxSpeed = 6
+	return cfg
+}
+
+// clouds of insects: pulled hard towards each other but hardly lining up,
+// so they buzz round in tight balls that slowly wander off
+func swarmConfig() Config {
+	cfg := DefaultConfig()
+	cfg.Neighbours = 20
+	cfg.SeparationFactor = 8
+	cfg.AlignmentWeight = 0.1
+	cfg.CohesionWeight = 1.5
+	cfg.WanderStrength = 1.5
+	cfg.MaxSpeed = 6
+	return cfg
+}
+
+// a school of fish: lined up with plenty of neighbours and turning
+// smoothly, so the whole flock streams along the same way at once and wheels
+// round together at the walls
+func schoolConfig() Config {
+	cfg := DefaultConfig()
+	cfg.Neighbours = 15
+	cfg.SeparationFactor = 20
+	cfg.AlignmentWeight = 2
+	cfg.CohesionWeight = 0.5
+	cfg.MaxSpeed = 8
+	cfg.MaxForce = 1
+	cfg.FOV = 270
+	return cfg
+}
+
+// a mill, the whole flock wheeling round and round the middle of the
+// window in a ring, following a ring of leaders that pull hard
+func vortexConfig() Config {
+	cfg := DefaultConfig()
+	cfg.Leaders = 30
+	cfg.LeaderRadius = 150
+	cfg.LeaderPeriod = 120
+	cfg.LeaderPull = 20
+	cfg.Neighbours = 15
+	cfg.SeparationFactor = 10
+	cfg.AlignmentWeight = 0.5
+	cfg.MaxSpeed = 8
+	cfg.MaxForce = 1
+	return cfg
+}
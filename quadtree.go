@@ -0,0 +1,158 @@
+package main
+
+import "math"
+
+// the deepest a quadtree goes, so goids sitting on top of each other don't
+// split it forever
+const maxQuadDepth = 24
+
+// quadTree is a Barnes-Hut tree of goid positions, for cohesion over a big
+// flock at long range. Each node knows the total weight and center of mass
+// of the goids under it, so a far off clump can be counted as if it were one
+// heavy goid without visiting them all. It only knows about x and y, so it's
+// only used in 2D.
+type quadTree struct {
+	nodes []quadNode
+	pull  float64 // how much a leader weighs, as in cohere
+}
+
+type quadNode struct {
+	min, max Vec2    // the box around the goids under the node
+	weight   float64 // their total weight, where leaders count for more
+	sum      Vec2    // their positions times their weights
+	children [4]int  // indexes of the quarters in the tree's nodes, -1 for none
+	leaf     bool    // no children, all the goids are at its center of mass
+}
+
+func newQuadTree(goids []*Goid, leaderPull float64) *quadTree {
+	t := &quadTree{nodes: make([]quadNode, 0, 2*len(goids)), pull: leaderPull}
+	if len(goids) > 0 {
+		// partitioned in place while building, so it mustn't be the
+		// flock's slice
+		t.build(append([]*Goid(nil), goids...), 0)
+	}
+	return t
+}
+
+// how much a goid counts towards the center of mass
+func (t *quadTree) weight(g *Goid) float64 {
+	if g.Leader {
+		return t.pull
+	}
+	return 1
+}
+
+// add a node for the goids, returning its index
+func (t *quadTree) build(goids []*Goid, depth int) int {
+	n := quadNode{min: goids[0].Pos, max: goids[0].Pos, children: [4]int{-1, -1, -1, -1}}
+	for _, g := range goids {
+		w := t.weight(g)
+		n.weight += w
+		n.sum = n.sum.Add(g.Pos.Scale(w))
+		n.min = Vec2{math.Min(n.min.X, g.Pos.X), math.Min(n.min.Y, g.Pos.Y)}
+		n.max = Vec2{math.Max(n.max.X, g.Pos.X), math.Max(n.max.Y, g.Pos.Y)}
+	}
+	i := len(t.nodes)
+	n.leaf = len(goids) == 1 || depth == maxQuadDepth || n.min == n.max
+	t.nodes = append(t.nodes, n)
+	if n.leaf {
+		return i
+	}
+	// split round the middle of the box, into left and right and then
+	// each of those into top and bottom
+	mid := n.min.Add(n.max).Scale(0.5)
+	left := partition(goids, func(g *Goid) bool { return g.Pos.X < mid.X })
+	quarters := [4][]*Goid{}
+	for h, half := range [2][]*Goid{goids[:left], goids[left:]} {
+		top := partition(half, func(g *Goid) bool { return g.Pos.Y < mid.Y })
+		quarters[2*h], quarters[2*h+1] = half[:top], half[top:]
+	}
+	for q, quarter := range quarters {
+		if len(quarter) > 0 {
+			child := t.build(quarter, depth+1)
+			t.nodes[i].children[q] = child
+		}
+	}
+	return i
+}
+
+// put the goids in is first, returning how many there are
+func partition(goids []*Goid, in func(*Goid) bool) int {
+	n := 0
+	for i, g := range goids {
+		if in(g) {
+			goids[n], goids[i] = goids[i], goids[n]
+			n++
+		}
+	}
+	return n
+}
+
+// the total weight and weighted positions of the goids within radius of g,
+// by the metric, including g itself if it's in the tree. A node that's small
+// next to how far away it is, by less than theta, counts as all or none of
+// its goids depending on whether its center of mass is within the radius.
+// A theta of 0 always looks at every goid that's near the edge of the radius,
+// so it's exact.
+func (t *quadTree) within(g *Goid, radius, theta float64, metric Metric) (weight float64, sum Vec2) {
+	if len(t.nodes) == 0 {
+		return
+	}
+	rSq := radius * radius
+	var visit func(i int)
+	visit = func(i int) {
+		n := &t.nodes[i]
+		// the nearest and furthest the box gets from g along each axis
+		var near, far Vec2
+		near.X, far.X = boxReach(g.Pos.X, n.min.X, n.max.X)
+		near.Y, far.Y = boxReach(g.Pos.Y, n.min.Y, n.max.Y)
+		if metric.lengthSq(near.Vec3()) > rSq {
+			return
+		}
+		if metric.lengthSq(far.Vec3()) <= rSq {
+			weight, sum = weight+n.weight, sum.Add(n.sum)
+			return
+		}
+		com := n.sum.Scale(1 / n.weight)
+		dSq := metric.distanceSq(g.pos3(), com.Vec3())
+		size := math.Max(n.max.X-n.min.X, n.max.Y-n.min.Y)
+		// the node g's in is never taken as a whole, so g's counted
+		// exactly once
+		inside := near == Vec2{}
+		if n.leaf || (!inside && size*size < theta*theta*dSq) {
+			if dSq <= rSq {
+				weight, sum = weight+n.weight, sum.Add(n.sum)
+			}
+			return
+		}
+		for _, c := range n.children {
+			if c >= 0 {
+				visit(c)
+			}
+		}
+	}
+	visit(0)
+	return
+}
+
+// the nearest and furthest distance from v to the range lo to hi
+func boxReach(v, lo, hi float64) (near, far float64) {
+	if v < lo {
+		near = lo - v
+	} else if v > hi {
+		near = v - hi
+	}
+	return near, math.Max(math.Abs(v-lo), math.Abs(v-hi))
+}
+
+// steer towards the center of mass of the goids in the tree within radius of
+// g, leaving g out, as a unit vector scaled by the weight, like cohere
+func (t *quadTree) cohere(g *Goid, radius, theta float64, metric Metric, weight float64) Vec3 {
+	w, sum := t.within(g, radius, theta, metric)
+	// g is always within range of itself, so it's always been counted
+	w, sum = w-t.weight(g), sum.Sub(g.Pos.Scale(t.weight(g)))
+	if w <= 1e-9 {
+		return Vec3{}
+	}
+	return sum.Scale(1 / w).Sub(g.Pos).Vec3().Normalize().Scale(weight)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// with a theta of 0 the tree's cohesion is exactly cohesion over every goid
+// within the radius, and with a bigger theta the center of mass it pulls
+// towards stays close to the real one
+func TestQuadTreeCohesion(t *testing.T) {
+	const radius = 150
+	for _, spawn := range []SpawnPattern{RandomSpawn, GroupsSpawn} {
+		cfg := DefaultConfig()
+		cfg.Population = 2000
+		cfg.Spawn = spawn
+		cfg.Leaders = 3
+		sim := NewSimulation(cfg)
+		tree := newQuadTree(sim.Goids, cfg.LeaderPull)
+		var near []Goid
+		for _, g := range sim.Goids[:200] {
+			near = near[:0]
+			for _, n := range sim.Goids {
+				if n != g && inRadius(g, n, Euclidean, radius) {
+					near = append(near, *n)
+				}
+			}
+			want := cohere(g, near, Euclidean, radius, 1, cfg.LeaderPull)
+			if got := tree.cohere(g, radius, 0, Euclidean, 1); got.Sub(want).Len() > 1e-9 {
+				t.Fatalf("%v spawn: with a theta of 0 goid %d cohered by %v, want %v", spawn, g.ID, got, want)
+			}
+		}
+		// how far the approximate center of mass is from the real one, as a
+		// fraction of the radius, at worst and on average
+		for _, bound := range []struct{ theta, worst, mean float64 }{{0.3, 0.15, 0.04}, {0.5, 0.25, 0.05}} {
+			var worst, total float64
+			for _, g := range sim.Goids {
+				w, sum := tree.within(g, radius, 0, Euclidean)
+				aw, asum := tree.within(g, radius, bound.theta, Euclidean)
+				off := asum.Scale(1/aw).Sub(sum.Scale(1/w)).Len() / radius
+				worst, total = math.Max(worst, off), total+off
+			}
+			if mean := total / float64(len(sim.Goids)); worst > bound.worst || mean > bound.mean {
+				t.Errorf("%v spawn, theta %g: the center of mass was off by up to %.3g of the radius and %.3g on average, want at most %g and %g",
+					spawn, bound.theta, worst, mean, bound.worst, bound.mean)
+			}
+		}
+	}
+}
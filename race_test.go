@@ -0,0 +1,7 @@
+//go:build race
+
+package main
+
+// the race detector makes allocations of its own, and sync.Pool drops what's
+// put in it at random, so allocations can't be counted
+const raceEnabled = true
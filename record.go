@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/csv"
+	"image/color"
+	"os"
+	"strconv"
+)
+
+// the columns of a recorded run, one row per goid per frame
+var csvHeader = []string{"frame", "id", "x", "y", "vx", "vy", "color"}
+
+// csvRecorder writes where every goid is each frame to a CSV file, for
+// analysing a run somewhere else
+type csvRecorder struct {
+	f *os.File
+	w *csv.Writer
+}
+
+// create the CSV file and write its header
+func newCSVRecorder(path string) (*csvRecorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &csvRecorder{f: f, w: csv.NewWriter(f)}
+	if err = r.w.Write(csvHeader); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// add a row for each goid in the frame
+func (r *csvRecorder) record(frame int, goids []*Goid) error {
+	row := make([]string, len(csvHeader))
+	for _, g := range goids {
+		row[0] = strconv.Itoa(frame)
+		row[1] = strconv.Itoa(g.ID)
+		row[2] = strconv.FormatFloat(g.Pos.X, 'f', -1, 64)
+		row[3] = strconv.FormatFloat(g.Pos.Y, 'f', -1, 64)
+		row[4] = strconv.FormatFloat(g.Vel.X, 'f', -1, 64)
+		row[5] = strconv.FormatFloat(g.Vel.Y, 'f', -1, 64)
+		row[6] = ""
+		if g.Color != nil {
+			row[6] = hexColor(color.RGBAModel.Convert(g.Color).(color.RGBA))
+		}
+		if err := r.w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush what's buffered and close the file
+func (r *csvRecorder) close() error {
+	r.w.Flush()
+	if err := r.w.Error(); err != nil {
+		r.f.Close()
+		return err
+	}
+	return r.f.Close()
+}
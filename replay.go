@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// replayReader reads a run recorded by csvRecorder back a frame at a time.
+// Frames can have different numbers of goids in them.
+type replayReader struct {
+	r      *csv.Reader
+	name   string // the name of the file, for errors
+	last   int    // the latest frame a row has been read for, -1 before the first
+	next   *Goid  // the first goid of the next frame, already read
+	nextAt int    // the frame the next goid is in
+	colors bool   // whether the goids' colors were recorded, which they weren't at first
+}
+
+// start reading a recorded run, checking it has the header csvRecorder writes
+func newReplayReader(r io.Reader, name string) (*replayReader, error) {
+	rr := &replayReader{r: csv.NewReader(r), name: name, last: -1}
+	header, err := rr.r.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%s is empty", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	switch strings.Join(header, ",") {
+	case strings.Join(csvHeader, ","):
+		rr.colors = true
+	case strings.Join(csvHeader[:len(csvHeader)-1], ","):
+		// recorded before colors were kept
+	default:
+		return nil, fmt.Errorf("%s line 1: header is %q, want %q", name, strings.Join(header, ","), strings.Join(csvHeader, ","))
+	}
+	return rr, nil
+}
+
+// read the next frame's number and goids, returning io.EOF after the last one
+func (rr *replayReader) frame(cfg Config) (frame int, goids []*Goid, err error) {
+	frame = -1
+	if rr.next != nil {
+		frame, goids = rr.nextAt, []*Goid{rr.next}
+		rr.next = nil
+	}
+	for {
+		f, g, err := rr.row(cfg)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		if frame == -1 {
+			frame = f
+		}
+		if f != frame {
+			rr.next, rr.nextAt = g, f
+			break
+		}
+		goids = append(goids, g)
+	}
+	if frame == -1 {
+		return 0, nil, io.EOF
+	}
+	return frame, goids, nil
+}
+
+// read and parse one goid's row. Goids get drawn with the configured size as
+// that isn't recorded, and the configured color if theirs wasn't.
+func (rr *replayReader) row(cfg Config) (frame int, g *Goid, err error) {
+	row, err := rr.r.Read()
+	if err == io.EOF {
+		return 0, nil, err
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %v", rr.name, err)
+	}
+	line, _ := rr.r.FieldPos(0)
+	invalid := func(i int) error {
+		return fmt.Errorf("%s line %d: invalid %s %q", rr.name, line, csvHeader[i], row[i])
+	}
+
+	if frame, err = strconv.Atoi(row[0]); err != nil || frame < 0 {
+		return 0, nil, invalid(0)
+	}
+	if frame < rr.last {
+		return 0, nil, fmt.Errorf("%s line %d: frame %d comes after frame %d", rr.name, line, frame, rr.last)
+	}
+	rr.last = frame
+	g = &Goid{R: cfg.GoidSize, Color: cfg.GoidColor}
+	if g.ID, err = strconv.Atoi(row[1]); err != nil {
+		return 0, nil, invalid(1)
+	}
+	for i, v := range []*float64{&g.Pos.X, &g.Pos.Y, &g.Vel.X, &g.Vel.Y} {
+		if *v, err = strconv.ParseFloat(row[i+2], 64); err != nil {
+			return 0, nil, invalid(i + 2)
+		}
+	}
+	if rr.colors && row[6] != "" {
+		c, err := parseHexColor(row[6])
+		if err != nil {
+			return 0, nil, invalid(6)
+		}
+		g.Color = c
+	}
+	return
+}
+
+// play a recorded run back through the simulation instead of stepping it,
+// calling show after each frame with its number, until the recording ends or
+// ctx is cancelled
+func replay(ctx context.Context, sim *Simulation, rr *replayReader, show func(i int)) error {
+	sim.Predators = nil
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		frame, goids, err := rr.frame(sim.Config)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		sim.Goids, sim.Frame = goids, frame+1
+		show(frame)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// there's no hearing about the terminal being resized here
+func notifyResize(c chan<- os.Signal) {}
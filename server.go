@@ -0,0 +1,464 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// how long a viewer gets to take a frame before it's dropped
+const writeTimeout = time.Second
+
+// server streams the flock to browsers over WebSocket, for watching without
+// a terminal that can show images, and takes changes to the flocking rules
+// as it goes over a small JSON API:
+//
+//	GET  /config  the tunable parameters, as a tuning
+//	POST /config  change any of them, leaving out the ones to keep
+//	GET  /stats   the flock's Stats as of the last frame
+//	GET  /metrics the same stats for Prometheus
+//
+// Browsers let any page they have open connect and POST to it, so only pages
+// it served itself or that are served from this machine get to watch and
+// tune it, unless anySite says pages from anywhere can.
+type server struct {
+	http      *http.Server
+	upgrader  websocket.Upgrader
+	anySite   bool
+	connected chan struct{} // closed when the first viewer connects
+	stop      func()        // ends the run, called when the last viewer disconnects
+	metrics   *metrics
+
+	// the handlers never touch the simulation, they only see it as it was
+	// at the last frame, and changes wait for the next one
+	mu      sync.Mutex
+	viewers map[*websocket.Conn]bool
+	config  Config
+	stats   Stats
+	pending []tuning
+}
+
+// tuning is the part of the config that can be changed on a running
+// simulation, with the parameters to leave alone left nil. The rule weights
+// are set for every species, if there are any.
+type tuning struct {
+	SeparationFactor *float64 `json:"separation_factor,omitempty"`
+	NumNeighbours    *int     `json:"num_neighbours,omitempty"`
+	SeparationWeight *float64 `json:"separation_weight,omitempty"`
+	AlignmentWeight  *float64 `json:"alignment_weight,omitempty"`
+	CohesionWeight   *float64 `json:"cohesion_weight,omitempty"`
+	CoherenceFactor  *float64 `json:"coherence_factor,omitempty"` // another way to set the cohesion weight, like -coherence
+	Population       *int     `json:"population,omitempty"`       // goids are added or removed to make it so
+}
+
+// the tunable parameters of a config. Species with different weights have
+// no one weight to show, so it's left out.
+func tuningOf(cfg Config) tuning {
+	t := tuning{SeparationFactor: &cfg.SeparationFactor, NumNeighbours: &cfg.Neighbours, Population: &cfg.Population}
+	species := cfg.species()
+	t.SeparationWeight = sharedWeight(species, func(sp Species) float64 { return sp.SeparationWeight })
+	t.AlignmentWeight = sharedWeight(species, func(sp Species) float64 { return sp.AlignmentWeight })
+	t.CohesionWeight = sharedWeight(species, func(sp Species) float64 { return sp.CohesionWeight })
+	if t.CohesionWeight != nil && *t.CohesionWeight > 0 {
+		coherence := defaultCoherence / *t.CohesionWeight
+		t.CoherenceFactor = &coherence
+	}
+	return t
+}
+
+// the weight every one of the species has, or nil if they differ
+func sharedWeight(species []Species, weight func(Species) float64) *float64 {
+	w := weight(species[0])
+	for _, sp := range species[1:] {
+		if weight(sp) != w {
+			return nil
+		}
+	}
+	return &w
+}
+
+// check the parameters that the config can't check once they're set
+func (t tuning) validate() error {
+	switch {
+	case t.CoherenceFactor == nil:
+	case *t.CoherenceFactor <= 0:
+		return fmt.Errorf("coherence factor must be positive, got %g", *t.CoherenceFactor)
+	case t.CohesionWeight != nil:
+		return fmt.Errorf("coherence_factor and cohesion_weight both set the cohesion weight, give one of them")
+	}
+	return nil
+}
+
+// set the parameters given in t
+func (t tuning) apply(cfg *Config) {
+	if t.SeparationFactor != nil {
+		cfg.SeparationFactor = *t.SeparationFactor
+	}
+	if t.NumNeighbours != nil {
+		cfg.Neighbours = *t.NumNeighbours
+	}
+	cohesion := t.CohesionWeight
+	if t.CoherenceFactor != nil {
+		w := cohesionWeightFor(*t.CoherenceFactor)
+		cohesion = &w
+	}
+	if t.SeparationWeight != nil || t.AlignmentWeight != nil || cohesion != nil {
+		// the species are shared with the config this was copied from
+		cfg.Species = slices.Clone(cfg.Species)
+	}
+	if t.SeparationWeight != nil {
+		cfg.SeparationWeight = *t.SeparationWeight
+		for i := range cfg.Species {
+			cfg.Species[i].SeparationWeight = *t.SeparationWeight
+		}
+	}
+	if t.AlignmentWeight != nil {
+		cfg.AlignmentWeight = *t.AlignmentWeight
+		for i := range cfg.Species {
+			cfg.Species[i].AlignmentWeight = *t.AlignmentWeight
+		}
+	}
+	if cohesion != nil {
+		cfg.CohesionWeight = *cohesion
+		for i := range cfg.Species {
+			cfg.Species[i].CohesionWeight = *cohesion
+		}
+	}
+	if t.Population != nil {
+		cfg.Population = *t.Population
+	}
+}
+
+// set the parameters given in t on a running simulation, adding or removing
+// goids to change the population
+func (t tuning) applyTo(sim *Simulation) {
+	if t.Population != nil {
+		if more := *t.Population - len(sim.Goids); more > 0 {
+			sim.Add(more)
+		} else {
+			sim.Remove(-more)
+		}
+	}
+	t.apply(&sim.Config)
+}
+
+// what's sent to the browser each frame. Goids are [x, y, vx, vy, species]
+// and predators [x, y, vx, vy], to keep the messages small.
+type frameMessage struct {
+	Frame      int          `json:"frame"`
+	Width      int          `json:"width"`
+	Height     int          `json:"height"`
+	Background string       `json:"background"`
+	Colors     []string     `json:"colors"` // the color of each species
+	Goids      [][5]float64 `json:"goids"`
+	Predators  [][4]float64 `json:"predators"`
+}
+
+// start serving the viewer page, the WebSocket it streams from and the API
+// for sim on addr, calling stop once everyone watching has gone. With
+// anySite, pages from any site can watch and tune it, not just its own.
+func startServer(addr string, sim *Simulation, stop func(), anySite bool) (*server, error) {
+	s := &server{
+		anySite:   anySite,
+		viewers:   make(map[*websocket.Conn]bool),
+		connected: make(chan struct{}),
+		stop:      stop,
+		metrics:   newMetrics(),
+		config:    sim.Config,
+		stats:     sim.Stats(),
+	}
+	s.upgrader.CheckOrigin = s.trusted
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, viewerPage)
+	})
+	mux.HandleFunc("/ws", s.serveWS)
+	mux.HandleFunc("/config", s.serveConfig)
+	mux.HandleFunc("/stats", s.serveStats)
+	mux.Handle("/metrics", s.metrics.handler())
+	s.http = &http.Server{Addr: addr, Handler: mux}
+	errs := make(chan error, 1)
+	go func() { errs <- s.http.ListenAndServe() }()
+	// give it a moment to fail on a bad or busy address
+	select {
+	case err := <-errs:
+		return nil, fmt.Errorf("serving on %s: %v", addr, err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	return s, nil
+}
+
+// add a viewer, and drop it when it goes away. Viewers never send anything,
+// reading is only to find out when they've gone.
+func (s *server) serveWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	first := len(s.viewers) == 0 && !s.started()
+	s.viewers[conn] = true
+	s.mu.Unlock()
+	if first {
+		close(s.connected)
+	}
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	s.drop(conn)
+}
+
+// show the tunable parameters, or change them from the next frame on
+func (s *server) serveConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.Lock()
+		cfg := s.config
+		s.mu.Unlock()
+		writeJSON(w, tuningOf(cfg))
+	case http.MethodPost:
+		if !s.trusted(r) {
+			http.Error(w, "tuning from pages on "+r.Header.Get("Origin")+" isn't allowed, see -serve-any-origin", http.StatusForbidden)
+			return
+		}
+		var t tuning
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&t); err != nil {
+			http.Error(w, "bad tuning: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := t.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		// check it against the config as it'll be once everything waiting
+		// has been applied
+		cfg := s.config
+		for _, p := range s.pending {
+			p.apply(&cfg)
+		}
+		t.apply(&cfg)
+		if err := cfg.Validate(); err != nil {
+			s.mu.Unlock()
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.pending = append(s.pending, t)
+		s.mu.Unlock()
+		writeJSON(w, tuningOf(cfg))
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "only GET and POST", http.StatusMethodNotAllowed)
+	}
+}
+
+// whether a request is from a page that's allowed to watch and tune the
+// run: one without an origin, which isn't from a browser page at all, one this
+// server sent, or one from this machine
+func (s *server) trusted(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if s.anySite || origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	host := u.Hostname()
+	ip := net.ParseIP(host)
+	return host == "localhost" || ip != nil && ip.IsLoopback()
+}
+
+// show the stats as of the last frame
+func (s *server) serveStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "only GET", http.StatusMethodNotAllowed)
+		return
+	}
+	s.mu.Lock()
+	st := s.stats
+	s.mu.Unlock()
+	writeJSON(w, st)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// apply the changes posted since the last frame, and take note of the
+// simulation as it is now for the handlers. This is called between steps, so
+// the simulation never sees a half changed config.
+func (s *server) sync(sim *Simulation) {
+	st := sim.Stats()
+	s.metrics.update(st)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.pending {
+		t.applyTo(sim)
+	}
+	s.pending = s.pending[:0]
+	s.config, s.stats = sim.Config, st
+}
+
+// whether any viewer has ever connected
+func (s *server) started() bool {
+	select {
+	case <-s.connected:
+		return true
+	default:
+		return false
+	}
+}
+
+// forget a viewer, ending the run if it was the last one
+func (s *server) drop(conn *websocket.Conn) {
+	conn.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.viewers[conn] {
+		return
+	}
+	delete(s.viewers, conn)
+	if len(s.viewers) == 0 {
+		s.stop()
+	}
+}
+
+// wait for the first viewer, returning false if ctx is cancelled first
+func (s *server) waitForViewer(ctx context.Context) bool {
+	select {
+	case <-s.connected:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// send the flock to every viewer, dropping any that can't keep up
+func (s *server) broadcast(sim *Simulation, frame int) {
+	data, err := json.Marshal(newFrameMessage(sim, frame))
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	viewers := make([]*websocket.Conn, 0, len(s.viewers))
+	for conn := range s.viewers {
+		viewers = append(viewers, conn)
+	}
+	s.mu.Unlock()
+	for _, conn := range viewers {
+		conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			s.drop(conn)
+		}
+	}
+}
+
+// say goodbye to the viewers and stop serving
+func (s *server) shutdown() {
+	s.mu.Lock()
+	for conn := range s.viewers {
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, "the run is over"), time.Now().Add(writeTimeout))
+	}
+	s.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	s.http.Shutdown(ctx)
+}
+
+func newFrameMessage(sim *Simulation, frame int) frameMessage {
+	cfg := sim.Config
+	m := frameMessage{
+		Frame:      frame,
+		Width:      cfg.Width,
+		Height:     cfg.Height,
+		Background: hexColor(cfg.BackgroundColor),
+		Goids:      make([][5]float64, len(sim.Goids)),
+		Predators:  make([][4]float64, len(sim.Predators)),
+	}
+	for _, sp := range cfg.species() {
+		m.Colors = append(m.Colors, hexColor(sp.Color))
+	}
+	for i, g := range sim.Goids {
+		m.Goids[i] = [5]float64{g.Pos.X, g.Pos.Y, g.Vel.X, g.Vel.Y, float64(g.Species)}
+	}
+	for i, p := range sim.Predators {
+		m.Predators[i] = [4]float64{p.Pos.X, p.Pos.Y, p.Vel.X, p.Vel.Y}
+	}
+	return m
+}
+
+// a color as CSS, like #c8c864
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// the page viewers load, which draws each frame it's sent on a canvas
+const viewerPage = `<!DOCTYPE html>
+<html>
+<head>
+<title>goids</title>
+<style>
+body { margin: 0; background: #111; color: #ccc; font-family: sans-serif; }
+canvas { display: block; margin: 0 auto; max-width: 100vw; max-height: 100vh; }
+#status { position: fixed; left: 8px; top: 8px; }
+</style>
+</head>
+<body>
+<canvas id="flock"></canvas>
+<div id="status">connecting...</div>
+<script>
+const canvas = document.getElementById("flock");
+const ctx = canvas.getContext("2d");
+const status = document.getElementById("status");
+const ws = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/ws");
+ws.onmessage = (event) => {
+	const f = JSON.parse(event.data);
+	canvas.width = f.width;
+	canvas.height = f.height;
+	ctx.fillStyle = f.background;
+	ctx.fillRect(0, 0, f.width, f.height);
+	for (const [x, y, vx, vy, species] of f.goids) {
+		ctx.fillStyle = f.colors[species] || "#c8c864";
+		ctx.beginPath();
+		ctx.arc(x, y, 3, 0, 2 * Math.PI);
+		ctx.moveTo(x, y);
+		ctx.lineTo(x - vx, y - vy);
+		ctx.fill();
+		ctx.strokeStyle = ctx.fillStyle;
+		ctx.stroke();
+	}
+	ctx.fillStyle = "#dc3c3c";
+	for (const [x, y] of f.predators) {
+		ctx.beginPath();
+		ctx.arc(x, y, 6, 0, 2 * Math.PI);
+		ctx.fill();
+	}
+	status.textContent = "frame " + f.frame;
+};
+ws.onclose = () => { status.textContent = "the run is over"; };
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTrustedOrigins(t *testing.T) {
+	tests := []struct {
+		origin  string
+		anySite bool
+		want    bool
+	}{
+		{"", false, true}, // curl and the like
+		{"http://example.com:8080", false, true},
+		{"http://EXAMPLE.com:8080", false, true},
+		{"http://localhost:3000", false, true},
+		{"http://127.0.0.1:3000", false, true},
+		{"http://[::1]:3000", false, true},
+		{"http://example.com:9090", false, false},
+		{"https://evil.example", false, false},
+		{"null", false, false},
+		{"https://evil.example", true, true},
+	}
+	for _, tt := range tests {
+		s := &server{anySite: tt.anySite}
+		r := httptest.NewRequest(http.MethodPost, "http://example.com:8080/config", strings.NewReader("{}"))
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := s.trusted(r); got != tt.want {
+			t.Errorf("origin %q with any site %v: trusted %v, want %v", tt.origin, tt.anySite, got, tt.want)
+		}
+	}
+}
+
+func TestTuningFromOtherSitesRefused(t *testing.T) {
+	for _, anySite := range []bool{false, true} {
+		s := &server{anySite: anySite, config: DefaultConfig()}
+		r := httptest.NewRequest(http.MethodPost, "http://example.com:8080/config", strings.NewReader(`{"cohesion_weight": 2}`))
+		r.Header.Set("Origin", "https://evil.example")
+		w := httptest.NewRecorder()
+		s.serveConfig(w, r)
+		if refused := w.Code == http.StatusForbidden; refused == anySite {
+			t.Errorf("any site %v: tuning from another site got %d", anySite, w.Code)
+		}
+		if queued := len(s.pending) == 1; queued != anySite {
+			t.Errorf("any site %v: %d tunings waiting after one from another site", anySite, len(s.pending))
+		}
+	}
+}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"context"
+	"image"
+	"math"
+	"math/rand"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Simulation is a flock of goids and the parameters they move by
+type Simulation struct {
+	Config    Config
+	Goids     []*Goid
+	Predators []*Predator
+	Frame     int        // number of steps taken so far
+	Census    Census     // births and deaths in the last step
+	Cursor    *Attractor // an attractor that moves, following the mouse, nil if there isn't one
+	// OnFrame, if set, is called by Run after each step with the index of
+	// the frame and a copy of the goids, so changing them doesn't change the
+	// flock. The copy is reused, so don't hang on to it after returning.
+	// Returning doesn't stop the run, cancel its context for that.
+	OnFrame    func(frame int, goids []*Goid)
+	rng        *rand.Rand
+	lastID     int           // the ID given to the last goid or predator created
+	next       []*Goid       // spare goids the next state is worked out in, swapped with Goids every step
+	view       []*Goid       // the copy of the goids handed to OnFrame
+	wanders    []Vec2        // each goid's wander for the step being worked out
+	lastFrame  *image.RGBA   // the last frame drawn, kept for trails
+	lastShown  time.Time     // when the last frame was shown, for the frame rate
+	fps        float64       // smoothed frames shown per second
+	undrawable int           // how many goids couldn't be drawn in the last frame
+	wind       *windField    // made when it's first needed
+	tails      map[int]*tail // where each goid's been drawn lately, by ID
+	cells      [][]*Goid     // the last grid's cells, which the next one's built in
+	draws      int           // how many frames have been drawn
+	stepTime   time.Duration // how long the last step took
+}
+
+// NewSimulation creates a simulation with a randomly placed population of goids
+func NewSimulation(cfg Config) *Simulation {
+	s := &Simulation{Config: cfg, rng: rand.New(rand.NewSource(cfg.Seed))}
+	if len(cfg.Species) > 0 {
+		s.Config.Population = 0
+		for _, sp := range cfg.Species {
+			s.Config.Population += sp.Count
+		}
+	}
+	s.Goids = make([]*Goid, 0, s.Config.Population)
+	s.populate()
+	return s
+}
+
+// Reset starts the simulation over with a new seed, putting the flock back to
+// its full population at random. The goids already there are reused in
+// place rather than making a whole new flock.
+func (s *Simulation) Reset(seed int64) {
+	s.Config.Seed = seed
+	s.rng.Seed(seed)
+	s.Frame, s.Census, s.lastID = 0, Census{}, 0
+	s.lastFrame, s.lastShown, s.fps, s.stepTime = nil, time.Time{}, 0, 0
+	// IDs start over too, so the old tails would belong to the new goids
+	clear(s.tails)
+	s.draws, s.undrawable = 0, 0
+	s.populate()
+}
+
+// Resize changes the size of the window, moving the goids and predators to
+// the same place in the new window as they were in the old one. Call it
+// between steps.
+func (s *Simulation) Resize(width, height int) {
+	sx := float64(width) / float64(s.Config.Width)
+	sy := float64(height) / float64(s.Config.Height)
+	for _, g := range s.Goids {
+		g.Pos = Vec2{g.Pos.X * sx, g.Pos.Y * sy}
+	}
+	for _, p := range s.Predators {
+		p.Pos = Vec2{p.Pos.X * sx, p.Pos.Y * sy}
+	}
+	s.Config.Width, s.Config.Height = width, height
+	// trails from the old size don't line up any more
+	s.lastFrame = nil
+}
+
+// randomly place the goids of each species and the predators, overwriting
+// any goids there already are
+func (s *Simulation) populate() {
+	old := s.Goids
+	s.Goids = s.Goids[:0]
+	for i, sp := range s.Config.species() {
+		for j := 0; j < sp.Count; j++ {
+			g := createRandomGoid(s.Config, s.rng, s.newID())
+			g.Species, g.Color = i, s.Config.Palette.color(g.ID, sp.Color)
+			// spread out how old they start so they don't all die at once
+			if s.Config.MaxAge > 0 {
+				g.Age = s.rng.Intn(s.Config.MaxAge)
+			}
+			if n := len(s.Goids); n < len(old) {
+				*old[n] = g
+				s.Goids = append(s.Goids, old[n])
+			} else {
+				s.Goids = append(s.Goids, &g)
+			}
+		}
+	}
+	spawn(s.Config, s.rng, s.Goids)
+	makeLeaders(s.Config, s.Goids)
+	s.Predators = s.Predators[:0]
+	for i := 0; i < s.Config.PredatorCount; i++ {
+		s.Predators = append(s.Predators, createRandomPredator(s.Config, s.rng, s.newID()))
+	}
+}
+
+// copy the goids into the view handed to OnFrame
+func (s *Simulation) copyGoids() []*Goid {
+	for len(s.view) < len(s.Goids) {
+		s.view = append(s.view, new(Goid))
+	}
+	view := s.view[:len(s.Goids)]
+	for i, g := range s.Goids {
+		*view[i] = *g
+	}
+	return view
+}
+
+// the ID for a new goid or predator
+func (s *Simulation) newID() int {
+	s.lastID++
+	return s.lastID
+}
+
+// Step moves the flock forward by one frame
+func (s *Simulation) Step() {
+	start := time.Now()
+	s.move()
+	if s.Config.Collide {
+		s.resolveCollisions()
+	}
+	s.Frame++
+	s.stepTime = time.Since(start)
+}
+
+// Run steps the simulation Config.Loops times, or forever if it's 0, calling
+// OnFrame after each step, and returns the number of steps taken. It stops
+// early if the flock converges, and with the context's error if ctx is
+// cancelled.
+func (s *Simulation) Run(ctx context.Context) (int, error) {
+	var conv *convergence
+	if s.Config.ConvergeChange > 0 {
+		conv = &convergence{change: s.Config.ConvergeChange, frames: s.Config.ConvergeFrames}
+	}
+	for i := 0; s.Config.Loops == 0 || i < s.Config.Loops; i++ {
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+		s.Step()
+		if s.OnFrame != nil {
+			s.OnFrame(i, s.copyGoids())
+		}
+		if conv != nil && conv.settled(polarization(s.Goids)) {
+			return i + 1, nil
+		}
+	}
+	return s.Config.Loops, nil
+}
+
+// move the goids with the 3 classic boid rules. The rules only steer, adding
+// up into a change of velocity, and each goid then moves exactly once by its
+// final velocity. Goids are worked out in parallel into the next state
+// buffer, which is then swapped with the current one, so every rule reads
+// where the flock was at the start of the frame, and the order of the goids
+// makes no difference.
+func (s *Simulation) move() {
+	cfg := s.Config
+	ix := s.index()
+	win := cfg.window()
+	k := s.neighbourCount()
+	species := cfg.species()
+	// the random source can't be shared between goroutines, so wandering
+	// is drawn up front and in order, keeping runs reproducible
+	wanders := s.wanders[:0]
+	for _, goid := range s.Goids {
+		wanders = append(wanders, s.wander(goid))
+	}
+	s.wanders = wanders
+	for len(s.next) < len(s.Goids) {
+		s.next = append(s.next, new(Goid))
+	}
+	next := s.next[:len(s.Goids)]
+	var wind *windField
+	if cfg.WindStrength > 0 {
+		wind = s.windField()
+	}
+	t := float64(s.Frame) * cfg.TimeStep
+	// long range cohesion, worked out from a tree for each species. The tree
+	// doesn't know about depth or wrapping round, so without it cohesion is
+	// just over the neighbours.
+	var cohesion []*quadTree
+	if cfg.CohesionTheta > 0 && cfg.Depth == 0 && !cfg.torus() {
+		cohesion = s.cohesionTrees(species)
+	}
+	attractors := cfg.Attractors
+	if s.Cursor != nil {
+		attractors = append(attractors[:len(attractors):len(attractors)], *s.Cursor)
+	}
+	parallel(len(s.Goids), func(i int) {
+		goid := s.Goids[i]
+		n := next[i]
+		*n = *goid
+		n.Age++
+		if goid.Leader {
+			followPath(cfg, n, float64(s.Frame+1)*cfg.TimeStep)
+			return
+		}
+		sp := species[goid.Species]
+		// goids keep their distance from everyone, but only flock with
+		// their own kind
+		sc := scratchPool.Get().(*scratch)
+		defer scratchPool.Put(sc)
+		sc.view.set(goid, cfg.FOV)
+		sc.neighbours = s.neighbours(sc.neighbours[:0], ix, goid, k, sc.sees)
+		neighbours, kin := sc.neighbours, sc.neighbours
+		if len(species) > 1 {
+			sc.kin = s.neighbours(sc.kin[:0], ix, goid, k, sc.seesKin)
+			kin = sc.kin
+		}
+
+		var steer Vec3
+		if flee := fleePredators(goid, s.Predators, cfg.DangerRadius); flee != (Vec2{}) {
+			// running for its life beats keeping up with the flock
+			steer = flee.Scale(sp.MaxSpeed).Vec3()
+		} else {
+			var coh Vec3
+			if cohesion != nil {
+				coh = cohesion[goid.Species].cohere(goid, cfg.CohesionRadius, cfg.CohesionTheta, cfg.Metric, sp.CohesionWeight)
+			} else {
+				coh = cohere(goid, kin, cfg.Metric, cfg.CohesionRadius, sp.CohesionWeight, cfg.LeaderPull)
+			}
+			steer = separate(goid, neighbours, cfg.Metric, cfg.SeparationFactor, sp.SeparationWeight).
+				Add(align(goid, kin, cfg.Metric, cfg.AlignmentRadius, sp.AlignmentWeight)).
+				Add(coh).
+				Add(wanders[i].Vec3()).
+				Add(attract(goid, attractors).Vec3())
+		}
+		steer = steer.Add(avoidEdges(goid, win, cfg.Depth, cfg.Margin, cfg.TurnFactor)).
+			Add(avoidObstacles(goid, cfg.Obstacles).Vec3())
+		if wind != nil {
+			steer = steer.Add(wind.at(goid.Pos, t, cfg.WindScale, cfg.WindStrength).Vec3())
+		}
+
+		n.Density = density(goid, neighbours, cfg.Metric, cfg.PerceptionRadius)
+		s.advance(n, win, steer, sp.MaxSpeed)
+	})
+	s.Goids, s.next = next, s.Goids
+	if cfg.MaxAge > 0 {
+		s.respawnOld()
+	}
+	// predators hunt the flock where it is now
+	s.movePredators(s.index())
+}
+
+// call f for every index from 0 to n, split between one goroutine per CPU
+// the program may use, and wait for them all to finish
+func parallel(n int, f func(i int)) {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > n {
+		workers = n
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(from, to int) {
+			defer wg.Done()
+			for i := from; i < to; i++ {
+				f(i)
+			}
+		}(n*w/workers, n*(w+1)/workers)
+	}
+	wg.Wait()
+}
+
+// a quadtree of the goids of each species, for cohesion
+func (s *Simulation) cohesionTrees(species []Species) []*quadTree {
+	bySpecies := make([][]*Goid, len(species))
+	for _, g := range s.Goids {
+		bySpecies[g.Species] = append(bySpecies[g.Species], g)
+	}
+	trees := make([]*quadTree, len(species))
+	for i, goids := range bySpecies {
+		trees[i] = newQuadTree(goids, s.Config.LeaderPull)
+	}
+	return trees
+}
+
+// the number of neighbours each goid looks for, as a small flock may not have
+// as many goids as we'd like
+func (s *Simulation) neighbourCount() int {
+	if k := len(s.Goids) - 1; k < s.Config.Neighbours {
+		return k
+	}
+	return s.Config.Neighbours
+}
+
+// how many of the neighbours are within the perception radius, which in
+// Nearest mode is at most the number of neighbours
+func density(g *Goid, neighbours []Goid, metric Metric, radius float64) (n int) {
+	for _, nb := range neighbours {
+		if metric.distanceSq(g.pos3(), nb.pos3()) <= radius*radius {
+			n++
+		}
+	}
+	return
+}
+
+// the neighbours of g that keep allows, picked according to the neighbour
+// mode and appended to dst
+func (s *Simulation) neighbours(dst []Goid, ix neighbourIndex, g *Goid, k int, keep func(*Goid) bool) []Goid {
+	if s.Config.NeighbourMode == Radius {
+		return ix.neighboursWithin(dst, g, s.Config.PerceptionRadius, keep)
+	}
+	return ix.nearestNeighbours(dst, g, k, keep)
+}
+
+// apply the steer to a goid's velocity, then move it by that velocity while
+// keeping it inside the window and out of obstacles. The window goes first,
+// as wrapping round or bouncing back can land a goid in an obstacle. Both
+// are scaled by the time step, so the steer is really a force, capped at
+// MaxForce and divided by the goid's mass. In 3D, goids bounce off the front
+// and back whatever the boundary mode.
+func (s *Simulation) advance(g *Goid, win Window, steer Vec3, maxSpeed float64) {
+	cfg := s.Config
+	if cfg.MaxForce > 0 && steer.Len() > cfg.MaxForce {
+		steer = steer.Normalize().Scale(cfg.MaxForce)
+	}
+	mass := g.Mass
+	if mass <= 0 {
+		// a goid made without a mass weighs the same as a default one
+		mass = 1
+	}
+	g.setVel3(g.vel3().Add(steer.Scale(cfg.TimeStep / mass)))
+	limitSpeed(g, maxSpeed)
+	g.Pos = g.Pos.Add(g.Vel.Scale(cfg.TimeStep))
+	if cfg.Depth > 0 {
+		g.Z, g.VZ = bounce(g.Z+g.VZ*cfg.TimeStep, g.VZ, float64(cfg.Depth))
+	}
+	win.Resolve(g, cfg.Boundary)
+	stayOutOfObstacles(g, cfg.Obstacles)
+}
+
+// scale the velocity down to maxSpeed if it's going too fast, keeping its direction
+func limitSpeed(g *Goid, maxSpeed float64) {
+	if g.speed() > maxSpeed {
+		g.setVel3(g.vel3().Normalize().Scale(maxSpeed))
+	}
+}
+
+// steer to avoid crowding local goids. Each neighbour pushes away in
+// proportion to the inverse of its distance, so the ones right on top of the
+// goid push much harder than the ones at the edge of the separation radius.
+// The total push is a unit vector scaled by the weight.
+func separate(g *Goid, neighbours []Goid, metric Metric, separationFactor, weight float64) (steer Vec3) {
+	for _, n := range neighbours {
+		d := metric.distanceSq(g.pos3(), n.pos3())
+		// goids sitting exactly on top of each other have no direction to push in
+		if d > 0 && d < separationFactor*separationFactor {
+			steer = steer.Add(g.pos3().Sub(n.pos3()).Scale(1 / d))
+		}
+	}
+	return steer.Normalize().Scale(weight)
+}
+
+// whether n is within radius of g, where a radius of 0 takes in everyone
+func inRadius(g *Goid, n *Goid, metric Metric, radius float64) bool {
+	return radius <= 0 || metric.distanceSq(g.pos3(), n.pos3()) <= radius*radius
+}
+
+// steer towards the average heading of the local goids within radius, if
+// there are any, as a unit vector scaled by the weight
+func align(g *Goid, neighbours []Goid, metric Metric, radius, weight float64) (steer Vec3) {
+	for i := range neighbours {
+		if inRadius(g, &neighbours[i], metric, radius) {
+			steer = steer.Add(neighbours[i].vel3())
+		}
+	}
+	return steer.Normalize().Scale(weight)
+}
+
+// steer to move toward the average position of the local goids within
+// radius, if there are any, as a unit vector scaled by the weight. Leaders
+// count leaderPull times as much as other goids towards the average.
+func cohere(g *Goid, neighbours []Goid, metric Metric, radius, weight, leaderPull float64) (steer Vec3) {
+	var p Vec3
+	var total float64
+	for i := range neighbours {
+		n := &neighbours[i]
+		if !inRadius(g, n, metric, radius) {
+			continue
+		}
+		w := 1.0
+		if n.Leader {
+			w = leaderPull
+		}
+		p = p.Add(n.pos3().Scale(w))
+		total += w
+	}
+	if total == 0 {
+		return
+	}
+	return p.Scale(1 / total).Sub(g.pos3()).Normalize().Scale(weight)
+}
+
+// steer back away from the edge of the window when close to it, harder the
+// closer the goid is. In 3D the front and back count as walls too.
+func avoidEdges(g *Goid, win Window, depth int, margin, turnFactor float64) (steer Vec3) {
+	if margin <= 0 {
+		return
+	}
+	steer = win.edgeTurn(g.Pos, margin).Vec3()
+	if depth > 0 {
+		steer.Z = edgeTurn(g.Z, float64(depth), margin)
+	}
+	return steer.Scale(turnFactor)
+}
+
+// steer in a small random direction, to keep a settled flock from getting
+// stuck. It draws from the simulation's random source so runs stay
+// reproducible, and does nothing (not even drawing) when WanderStrength is 0.
+func (s *Simulation) wander(g *Goid) (steer Vec2) {
+	if s.Config.WanderStrength <= 0 {
+		return
+	}
+	angle := s.rng.Float64() * 2 * math.Pi
+	return Vec2{math.Cos(angle), math.Sin(angle)}.Scale(s.Config.WanderStrength)
+}
+
+// how far p is into the margin at either end of [0, size], as a fraction of
+// the margin, positive pointing away from the wall
+func edgeTurn(p, size, margin float64) float64 {
+	if p < margin {
+		return (margin - p) / margin
+	}
+	if p > size-margin {
+		return -(p - (size - margin)) / margin
+	}
+	return 0
+}
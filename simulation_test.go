@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRunCancel(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Loops = 0
+	sim := NewSimulation(cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sim.OnFrame = func(i int, _ []*Goid) {
+		if i == 2 {
+			cancel()
+		}
+	}
+	type result struct {
+		n   int
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		n, err := sim.Run(ctx)
+		done <- result{n, err}
+	}()
+	select {
+	case r := <-done:
+		if r.n != 3 || !errors.Is(r.err, context.Canceled) {
+			t.Errorf("Run returned %d, %v, want 3, %v", r.n, r.err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return after being cancelled")
+	}
+}
+
+func TestStepSmallFlock(t *testing.T) {
+	for _, mode := range []NeighbourMode{Nearest, Radius} {
+		for _, population := range []int{1, 2, 3} {
+			cfg := DefaultConfig()
+			cfg.Population = population
+			cfg.Neighbours = 7
+			cfg.NeighbourMode = mode
+			sim := NewSimulation(cfg)
+			for i := 0; i < 20; i++ {
+				sim.Step()
+			}
+			for _, g := range sim.Goids {
+				if !g.Pos.finite() || !g.Vel.finite() {
+					t.Errorf("%v mode, %d goids: goid %d ended up at %v going %v", mode, population, g.ID, g.Pos, g.Vel)
+				}
+			}
+		}
+	}
+}
+
+func TestStepMovesByVelocity(t *testing.T) {
+	cfg := DefaultConfig()
+	sim := NewSimulation(cfg)
+	for i := 0; i < 10; i++ {
+		before := make(map[int]Vec2)
+		for _, g := range sim.Goids {
+			before[g.ID] = g.Pos
+		}
+		sim.Step()
+		for _, g := range sim.Goids {
+			// the short way round, for goids that wrapped over an edge
+			moved := Vec2{
+				wrapOffset(g.Pos.X-before[g.ID].X, float64(cfg.Width)),
+				wrapOffset(g.Pos.Y-before[g.ID].Y, float64(cfg.Height)),
+			}
+			if d := moved.Sub(g.Vel.Scale(cfg.TimeStep)); d.Len() > 1e-9 {
+				t.Fatalf("step %d: goid %d moved by %v with a velocity of %v", i, g.ID, moved, g.Vel)
+			}
+		}
+	}
+}
+
+func TestTimeStep(t *testing.T) {
+	// a lone goid has nothing to steer it, so it just moves in a line
+	run := func(dt float64, steps int) Vec2 {
+		cfg := DefaultConfig()
+		cfg.Population = 1
+		cfg.Seed = 7
+		cfg.TimeStep = dt
+		sim := NewSimulation(cfg)
+		for i := 0; i < steps; i++ {
+			sim.Step()
+		}
+		return sim.Goids[0].Pos
+	}
+	once, twice := run(1, 1), run(0.5, 2)
+	if once.Sub(twice).Len() > 1e-9 {
+		t.Errorf("2 steps at dt 0.5 went to %v, 1 step at dt 1 to %v", twice, once)
+	}
+	// and stepping at twice the frame rate gets there in twice the steps
+	if at60, at30 := run(frameTimeStep(60), 2), run(frameTimeStep(30), 1); at60.Sub(at30).Len() > 1e-9 {
+		t.Errorf("2 steps at 60 fps went to %v, 1 at 30 fps to %v", at60, at30)
+	}
+}
+
+func BenchmarkStep(b *testing.B) {
+	for _, n := range []int{150, 1500, 10000} {
+		cfg := DefaultConfig()
+		cfg.Population = n
+		sim := NewSimulation(cfg)
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				sim.Step()
+			}
+		})
+	}
+}
+
+// step a big flock on more and more CPUs
+func BenchmarkStepParallel(b *testing.B) {
+	cfg := DefaultConfig()
+	cfg.Population = 10000
+	sim := NewSimulation(cfg)
+	procs := runtime.GOMAXPROCS(0)
+	defer runtime.GOMAXPROCS(procs)
+	for p := 1; ; p *= 2 {
+		p = min(p, runtime.NumCPU())
+		b.Run(fmt.Sprintf("cpus=%d", p), func(b *testing.B) {
+			runtime.GOMAXPROCS(p)
+			for i := 0; i < b.N; i++ {
+				sim.Step()
+			}
+		})
+		if p == runtime.NumCPU() {
+			break
+		}
+	}
+}
+
+func TestStepOrderIndependent(t *testing.T) {
+	for _, mode := range []NeighbourMode{Nearest, Radius} {
+		cfg := DefaultConfig()
+		cfg.NeighbourMode = mode
+		cfg.Seed = 11
+		forwards, backwards := NewSimulation(cfg), NewSimulation(cfg)
+		slices.Reverse(backwards.Goids)
+		for i := 0; i < 5; i++ {
+			forwards.Step()
+			backwards.Step()
+		}
+		byID := make(map[int]*Goid)
+		for _, g := range backwards.Goids {
+			byID[g.ID] = g
+		}
+		for _, g := range forwards.Goids {
+			b := byID[g.ID]
+			if g.Pos.Sub(b.Pos).Len() > 1e-9 || g.Vel.Sub(b.Vel).Len() > 1e-9 {
+				t.Fatalf("%v mode: goid %d is at %v going %v, or %v going %v the other way round", mode, g.ID, g.Pos, g.Vel, b.Pos, b.Vel)
+			}
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.TailSegments = 4
+	sim := NewSimulation(cfg)
+	for i := 0; i < 5; i++ {
+		sim.Step()
+		draw(sim)
+	}
+	before := make([]Vec2, len(sim.Goids))
+	for i, g := range sim.Goids {
+		before[i] = g.Pos
+	}
+	goids := cap(sim.Goids)
+	sim.Reset(2)
+	if sim.Frame != 0 || sim.draws != 0 || len(sim.tails) != 0 {
+		t.Errorf("after a reset, frame %d, %d frames drawn and %d tails, want none", sim.Frame, sim.draws, len(sim.tails))
+	}
+	if len(sim.Goids) != cfg.Population || cap(sim.Goids) != goids {
+		t.Errorf("after a reset, %d goids with room for %d, want %d with room for %d", len(sim.Goids), cap(sim.Goids), cfg.Population, goids)
+	}
+	same := 0
+	for i, g := range sim.Goids {
+		if g.Pos == before[i] {
+			same++
+		}
+	}
+	if same > 0 {
+		t.Errorf("%d goids are where they were before the reset", same)
+	}
+}
+
+func TestRulesCappedByWeight(t *testing.T) {
+	all := randomGoids(100, 5)
+	neighbours := make([]Goid, len(all)-1)
+	for i, n := range all[1:] {
+		neighbours[i] = *n
+	}
+	// sitting right on top of the goid, where separation pushes hardest
+	neighbours[0].Pos = all[0].Pos.Add(Vec2{0.01, 0})
+	for _, weight := range []float64{0, 0.5, 1, 5} {
+		for k := 0; k <= len(neighbours); k += 11 {
+			g, ns := all[0], neighbours[:k]
+			rules := map[string]Vec3{
+				"separate": separate(g, ns, Euclidean, 1000, weight),
+				"align":    align(g, ns, Euclidean, 0, weight),
+				"cohere":   cohere(g, ns, Euclidean, 0, weight, 1),
+			}
+			for name, steer := range rules {
+				if steer.Len() > weight+1e-9 {
+					t.Errorf("%s with %d neighbours and a weight of %g steered by %g", name, k, weight, steer.Len())
+				}
+			}
+		}
+	}
+}
+
+// a step allocates about the same few things however many goids there are,
+// in every neighbour mode and index
+func TestStepAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations can't be counted with the race detector")
+	}
+	for _, mode := range []NeighbourMode{Nearest, Radius} {
+		for _, ix := range []NeighbourIndex{GridIndex, KDTreeIndex, BruteIndex} {
+			cfg := DefaultConfig()
+			cfg.NeighbourMode, cfg.NeighbourIndex = mode, ix
+			cfg.Population = 500
+			sim := NewSimulation(cfg)
+			// the first step fills up the buffers the rest reuse
+			sim.Step()
+			if allocs := testing.AllocsPerRun(5, sim.Step); allocs > 50 {
+				t.Errorf("%v mode with the %v: a step of %d goids made %g allocations", mode, ix, cfg.Population, allocs)
+			}
+		}
+	}
+}
+
+func BenchmarkStepAllocs(b *testing.B) {
+	for _, mode := range []NeighbourMode{Nearest, Radius} {
+		for _, ix := range []NeighbourIndex{GridIndex, KDTreeIndex, BruteIndex} {
+			cfg := DefaultConfig()
+			cfg.NeighbourMode, cfg.NeighbourIndex = mode, ix
+			cfg.Population = 1500
+			sim := NewSimulation(cfg)
+			sim.Step()
+			b.Run(fmt.Sprintf("%v/%v", mode, ix), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					sim.Step()
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+)
+
+// the number of levels per channel in the color cube frames are quantized to
+const sixelLevels = 6
+
+// quantize a color down to its index in the sixel color cube
+func sixelIndex(r, g, b uint32) int {
+	q := func(v uint32) int { return int((v>>8)*(sixelLevels-1)+127) / 255 }
+	return (q(r)*sixelLevels+q(g))*sixelLevels + q(b)
+}
+
+// encode an image as sixels. Frames are anti-aliased so the colors are first
+// quantized to a 6x6x6 cube, and only the cube colors that are actually used
+// get registered in the palette.
+func encodeSixel(img image.Image) []byte {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	pixels := make([]int, w*h)
+	used := make([]bool, sixelLevels*sixelLevels*sixelLevels)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			i := sixelIndex(r, g, b)
+			pixels[y*w+x] = i
+			used[i] = true
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "\x1bPq\"1;1;%d;%d", w, h)
+	step := 100 / (sixelLevels - 1)
+	for i := range used {
+		if !used[i] {
+			continue
+		}
+		r, g, b := i/(sixelLevels*sixelLevels), (i/sixelLevels)%sixelLevels, i%sixelLevels
+		fmt.Fprintf(&buf, "#%d;2;%d;%d;%d", i, r*step, g*step, b*step)
+	}
+
+	// each band is 6 pixels high, drawn one color at a time
+	for top := 0; top < h; top += 6 {
+		bands := make(map[int][]byte)
+		var order []int
+		for dy := 0; dy < 6 && top+dy < h; dy++ {
+			for x := 0; x < w; x++ {
+				i := pixels[(top+dy)*w+x]
+				band, ok := bands[i]
+				if !ok {
+					band = make([]byte, w)
+					bands[i] = band
+					order = append(order, i)
+				}
+				band[x] |= 1 << uint(dy)
+			}
+		}
+		for n, i := range order {
+			if n > 0 {
+				buf.WriteByte('$')
+			}
+			fmt.Fprintf(&buf, "#%d", i)
+			writeSixelRun(&buf, bands[i])
+		}
+		buf.WriteByte('-')
+	}
+	buf.WriteString("\x1b\\")
+	return buf.Bytes()
+}
+
+// write a row of sixels, run-length encoding repeated ones
+func writeSixelRun(buf *bytes.Buffer, band []byte) {
+	for x := 0; x < len(band); {
+		n := 1
+		for x+n < len(band) && band[x+n] == band[x] {
+			n++
+		}
+		c := band[x] + 63
+		if n > 3 {
+			fmt.Fprintf(buf, "!%d%c", n, c)
+		} else {
+			for j := 0; j < n; j++ {
+				buf.WriteByte(c)
+			}
+		}
+		x += n
+	}
+}
+
+// this works for terminals that support sixel graphics, like xterm (started
+// with sixel support), mlterm and foot
+func printImageSixel(w io.Writer, img image.Image) error {
+	if _, err := fmt.Fprint(w, "\x1b[2;0H"); err != nil {
+		return err
+	}
+	_, err := w.Write(encodeSixel(img))
+	return err
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// SpawnPattern is where the flock starts out
+type SpawnPattern int
+
+const (
+	RandomSpawn  SpawnPattern = iota // scattered all over the window
+	ClusterSpawn                     // in a tight bunch in the middle
+	RingSpawn                        // spaced out round a ring
+	GridSpawn                        // in rows and columns across the window
+	GroupsSpawn                      // in 2 bunches on either side of the window
+)
+
+var spawnPatterns = []string{"random", "cluster", "ring", "grid", "groups"}
+
+func (p SpawnPattern) String() string { return enumName(spawnPatterns, int(p)) }
+
+// MarshalText lets the pattern be used as a flag and in JSON by name
+func (p SpawnPattern) MarshalText() ([]byte, error) { return []byte(p.String()), nil }
+
+// UnmarshalText parses the name of a pattern
+func (p *SpawnPattern) UnmarshalText(text []byte) error {
+	return parseEnum(spawnPatterns, text, "spawn pattern", (*int)(p))
+}
+
+// SpawnHeading is which way the flock starts out flying
+type SpawnHeading int
+
+const (
+	RandomHeading  SpawnHeading = iota // every which way
+	OutwardHeading                     // away from the middle of the pattern
+	InwardHeading                      // towards the middle of the pattern
+)
+
+var spawnHeadings = []string{"random", "outward", "inward"}
+
+func (h SpawnHeading) String() string { return enumName(spawnHeadings, int(h)) }
+
+// MarshalText lets the heading be used as a flag and in JSON by name
+func (h SpawnHeading) MarshalText() ([]byte, error) { return []byte(h.String()), nil }
+
+// UnmarshalText parses the name of a heading
+func (h *SpawnHeading) UnmarshalText(text []byte) error {
+	return parseEnum(spawnHeadings, text, "spawn heading", (*int)(h))
+}
+
+// move the newly made goids into the spawn pattern, and turn them to the
+// spawn heading keeping their speed. They start out random, so the default
+// pattern and heading leave them be.
+func spawn(cfg Config, rng *rand.Rand, goids []*Goid) {
+	if cfg.Spawn == RandomSpawn && cfg.SpawnHeading == RandomHeading {
+		return
+	}
+	w, h := float64(cfg.Width), float64(cfg.Height)
+	win := cfg.window()
+	centre := Vec2{w / 2, h / 2}
+	// how far a bunch spreads, as the standard deviation
+	spread := math.Min(w, h) / 12
+	cols := int(math.Ceil(math.Sqrt(float64(len(goids)) * w / h)))
+	rows := (len(goids) + cols - 1) / max(1, cols)
+	for i, g := range goids {
+		// the middle of the part of the pattern the goid is in
+		from := centre
+		switch cfg.Spawn {
+		case ClusterSpawn:
+			g.Pos = centre.Add(Vec2{rng.NormFloat64(), rng.NormFloat64()}.Scale(spread))
+		case RingSpawn:
+			angle := 2 * math.Pi * float64(i) / float64(len(goids))
+			g.Pos = centre.Add(Vec2{math.Cos(angle), math.Sin(angle)}.Scale(math.Min(w, h) / 3))
+		case GridSpawn:
+			g.Pos = Vec2{(float64(i%cols) + 0.5) * w / float64(cols), (float64(i/cols) + 0.5) * h / float64(rows)}
+		case GroupsSpawn:
+			from = Vec2{w / 4, h / 2}
+			if i%2 == 1 {
+				from.X = w * 3 / 4
+			}
+			g.Pos = from.Add(Vec2{rng.NormFloat64(), rng.NormFloat64()}.Scale(spread))
+		}
+		g.Pos = Vec2{math.Max(0, math.Min(w-1, g.Pos.X)), math.Max(0, math.Min(h-1, g.Pos.Y))}
+		if cfg.Spawn != RandomSpawn {
+			// random goids were already made inside the window
+			g.Pos = win.fit(g.Pos)
+		}
+
+		if cfg.SpawnHeading == RandomHeading {
+			continue
+		}
+		speed := g.Vel.Len()
+		if speed == 0 {
+			speed = float64(cfg.GoidSize)
+		}
+		away := g.Pos.Sub(from).Normalize()
+		if away == (Vec2{}) {
+			// right in the middle, any way will do
+			away = Vec2{1, 0}
+		}
+		if cfg.SpawnHeading == InwardHeading {
+			away = away.Scale(-1)
+		}
+		g.Vel = away.Scale(speed)
+	}
+}
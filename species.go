@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"os"
+)
+
+// Species is a group of goids that only flock with each other, with their
+// own color, rule weights and top speed. Goids of every species still keep
+// their distance from each other.
+type Species struct {
+	Count            int        `json:"count"`
+	Color            color.RGBA `json:"color"`
+	SeparationWeight float64    `json:"separation_weight"`
+	AlignmentWeight  float64    `json:"alignment_weight"`
+	CohesionWeight   float64    `json:"cohesion_weight"`
+	MaxSpeed         float64    `json:"max_speed"`
+}
+
+// the species in the simulation, or a single species made up from the top
+// level parameters if none are configured
+func (c Config) species() []Species {
+	if len(c.Species) > 0 {
+		return c.Species
+	}
+	return []Species{{
+		Count:            c.Population,
+		Color:            c.GoidColor,
+		SeparationWeight: c.SeparationWeight,
+		AlignmentWeight:  c.AlignmentWeight,
+		CohesionWeight:   c.CohesionWeight,
+		MaxSpeed:         c.MaxSpeed,
+	}}
+}
+
+// check a species makes sense
+func (sp Species) validate() error {
+	switch {
+	case sp.Count <= 0:
+		return fmt.Errorf("species count must be positive, got %d", sp.Count)
+	case sp.SeparationWeight < 0 || sp.AlignmentWeight < 0 || sp.CohesionWeight < 0:
+		return fmt.Errorf("species rule weights must not be negative, got %g, %g and %g", sp.SeparationWeight, sp.AlignmentWeight, sp.CohesionWeight)
+	case sp.MaxSpeed <= 0:
+		return fmt.Errorf("species max speed must be positive, got %g", sp.MaxSpeed)
+	}
+	return nil
+}
+
+// read a JSON array of species from a file, each one like
+// {"count": 100, "color": {"R": 200, "G": 200, "B": 100, "A": 255},
+// "separation_weight": 5, "alignment_weight": 1, "cohesion_weight": 2, "max_speed": 12}
+func loadSpecies(path string) (species []Species, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &species); err != nil {
+		err = fmt.Errorf("reading species from %s: %v", path, err)
+	}
+	return
+}
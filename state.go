@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"math/rand"
+)
+
+// the JSON form of a simulation. Goid colors are an interface, so they're
+// saved as the plain RGBA they're drawn in.
+type savedState struct {
+	Config    Config          `json:"config"`
+	Frame     int             `json:"frame"`
+	Goids     []savedGoid     `json:"goids"`
+	Predators []savedPredator `json:"predators"`
+}
+
+type savedGoid struct {
+	ID      int        `json:"id"`
+	Pos     Vec2       `json:"pos"`
+	Vel     Vec2       `json:"vel"`
+	Z       float64    `json:"z,omitempty"`
+	VZ      float64    `json:"vz,omitempty"`
+	R       int        `json:"r"`
+	Mass    float64    `json:"mass"`
+	Color   color.RGBA `json:"color"`
+	Species int        `json:"species"`
+	Density int        `json:"density"`
+	Age     int        `json:"age,omitempty"`
+	Leader  bool       `json:"leader,omitempty"`
+	Phase   float64    `json:"leader_phase,omitempty"`
+}
+
+type savedPredator struct {
+	savedGoid
+	Energy float64 `json:"energy"`
+}
+
+func saveGoid(g *Goid) savedGoid {
+	var c color.RGBA
+	if g.Color != nil {
+		c = color.RGBAModel.Convert(g.Color).(color.RGBA)
+	}
+	return savedGoid{ID: g.ID, Pos: g.Pos, Vel: g.Vel, Z: g.Z, VZ: g.VZ, R: g.R, Mass: g.Mass, Color: c, Species: g.Species, Density: g.Density, Age: g.Age, Leader: g.Leader, Phase: g.LeaderPhase}
+}
+
+func (sg savedGoid) goid() Goid {
+	return Goid{ID: sg.ID, Pos: sg.Pos, Vel: sg.Vel, Z: sg.Z, VZ: sg.VZ, R: sg.R, Mass: sg.Mass, Color: sg.Color, Species: sg.Species, Density: sg.Density, Age: sg.Age, Leader: sg.Leader, LeaderPhase: sg.Phase}
+}
+
+// Save writes the config and every goid and predator to w as JSON
+func (s *Simulation) Save(w io.Writer) error {
+	state := savedState{Config: s.Config, Frame: s.Frame}
+	for _, g := range s.Goids {
+		state.Goids = append(state.Goids, saveGoid(g))
+	}
+	for _, p := range s.Predators {
+		state.Predators = append(state.Predators, savedPredator{saveGoid(&p.Goid), p.Energy})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(state)
+}
+
+// Load replaces the simulation with one saved by Save. The random source
+// can't be saved, so it starts again from the seed, and anything random
+// (wandering, breeding) won't go the way it did in the saved run.
+func (s *Simulation) Load(r io.Reader) error {
+	var state savedState
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return fmt.Errorf("loading simulation: %v", err)
+	}
+	if err := state.Config.Validate(); err != nil {
+		return fmt.Errorf("loading simulation: %v", err)
+	}
+	species := len(state.Config.species())
+	*s = Simulation{Config: state.Config, Frame: state.Frame, OnFrame: s.OnFrame, rng: rand.New(rand.NewSource(state.Config.Seed))}
+	for _, sg := range state.Goids {
+		if sg.Species < 0 || sg.Species >= species {
+			return fmt.Errorf("loading simulation: goid of species %d, but there are only %d", sg.Species, species)
+		}
+		g := sg.goid()
+		s.Goids = append(s.Goids, &g)
+		s.lastID = max(s.lastID, g.ID)
+	}
+	for _, sp := range state.Predators {
+		s.Predators = append(s.Predators, &Predator{Goid: sp.goid(), Energy: sp.Energy})
+		s.lastID = max(s.lastID, sp.ID)
+	}
+	return nil
+}
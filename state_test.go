@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestSaveLoad(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Depth = 200
+	cfg.PredatorCount = 2
+	cfg.Leaders = 1
+	cfg.Obstacles = []Obstacle{{Pos: Vec2{400, 300}, Radius: 50}}
+	cfg.Species = []Species{
+		{Count: 30, Color: color.RGBA{200, 80, 80, 255}, SeparationWeight: 1.5, AlignmentWeight: 1, CohesionWeight: 1, MaxSpeed: 8},
+		{Count: 20, Color: color.RGBA{80, 80, 200, 255}, SeparationWeight: 1, AlignmentWeight: 2, CohesionWeight: 1, MaxSpeed: 10},
+	}
+	sim := NewSimulation(cfg)
+	for i := 0; i < 5; i++ {
+		sim.Step()
+	}
+	var b bytes.Buffer
+	if err := sim.Save(&b); err != nil {
+		t.Fatal(err)
+	}
+	loaded := &Simulation{}
+	if err := loaded.Load(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Config, sim.Config) {
+		t.Errorf("loaded config\n%+v\nwant\n%+v", loaded.Config, sim.Config)
+	}
+	if loaded.Frame != sim.Frame {
+		t.Errorf("loaded frame %d, want %d", loaded.Frame, sim.Frame)
+	}
+	if len(loaded.Goids) != len(sim.Goids) {
+		t.Fatalf("loaded %d goids, want %d", len(loaded.Goids), len(sim.Goids))
+	}
+	for i, g := range sim.Goids {
+		if !reflect.DeepEqual(*loaded.Goids[i], *g) {
+			t.Errorf("loaded goid\n%+v\nwant\n%+v", *loaded.Goids[i], *g)
+		}
+	}
+	if len(loaded.Predators) != len(sim.Predators) {
+		t.Fatalf("loaded %d predators, want %d", len(loaded.Predators), len(sim.Predators))
+	}
+	for i, p := range sim.Predators {
+		if !reflect.DeepEqual(*loaded.Predators[i], *p) {
+			t.Errorf("loaded predator\n%+v\nwant\n%+v", *loaded.Predators[i], *p)
+		}
+	}
+}
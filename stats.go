@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Stats are the usual measures of how ordered a flock is
+type Stats struct {
+	Frame           int
+	Population      int
+	AverageSpeed    float64
+	NearestDistance float64       // average distance from each goid to its nearest neighbour, by the config's metric
+	Polarization    float64       // length of the average heading, 1 when all goids fly the same way and near 0 when they're all over the place
+	Min, Max        Vec2          // the bounding box of the flock
+	FPS             float64       // smoothed frames shown per second
+	StepTime        time.Duration // how long the last step took
+}
+
+// keep a smoothed estimate of how many frames are shown a second, from the
+// time since the last one. It's called once a frame by whatever's showing
+// them, drawn or not, so it's just as right when serving to browsers.
+func (sim *Simulation) tickFPS() {
+	now := time.Now()
+	if !sim.lastShown.IsZero() {
+		if dt := now.Sub(sim.lastShown).Seconds(); dt > 0 {
+			if sim.fps == 0 {
+				sim.fps = 1 / dt
+			} else {
+				sim.fps = 0.9*sim.fps + 0.1/dt
+			}
+		}
+	}
+	sim.lastShown = now
+}
+
+// Stats measures the flock as it is now. Nearest neighbours are looked up in
+// the neighbour index, so it doesn't take a pass over every pair of goids
+// unless that's the index picked.
+func (s *Simulation) Stats() (st Stats) {
+	st.Frame, st.Population = s.Frame, len(s.Goids)
+	st.FPS, st.StepTime = s.fps, s.stepTime
+	if len(s.Goids) == 0 {
+		return
+	}
+	ix := s.index()
+	st.Min = Vec2{math.Inf(1), math.Inf(1)}
+	st.Max = Vec2{math.Inf(-1), math.Inf(-1)}
+	for _, g := range s.Goids {
+		st.AverageSpeed += g.speed()
+		if nearest := ix.nearestNeighbours(nil, g, 1, nil); len(nearest) > 0 {
+			st.NearestDistance += math.Sqrt(s.Config.Metric.distanceSq(g.pos3(), nearest[0].pos3()))
+		}
+		st.Min = Vec2{math.Min(st.Min.X, g.Pos.X), math.Min(st.Min.Y, g.Pos.Y)}
+		st.Max = Vec2{math.Max(st.Max.X, g.Pos.X), math.Max(st.Max.Y, g.Pos.Y)}
+	}
+	n := float64(len(s.Goids))
+	st.AverageSpeed /= n
+	st.NearestDistance /= n
+	st.Polarization = polarization(s.Goids)
+	return
+}
+
+// print st a line at a time, for a run with nothing else to show them in
+func printStats(w io.Writer, st Stats) {
+	fmt.Fprintf(w, "frames  %d\n", st.Frame)
+	fmt.Fprintf(w, "goids   %d\n", st.Population)
+	fmt.Fprintf(w, "speed   %.2f\n", st.AverageSpeed)
+	fmt.Fprintf(w, "nearest %.2f\n", st.NearestDistance)
+	fmt.Fprintf(w, "order   %.2f\n", st.Polarization)
+	fmt.Fprintf(w, "bounds  (%.0f, %.0f) to (%.0f, %.0f)\n", st.Min.X, st.Min.Y, st.Max.X, st.Max.Y)
+	fmt.Fprintf(w, "step    %v\n", st.StepTime)
+}
+
+// the length of the goids' average heading
+func polarization(goids []*Goid) float64 {
+	if len(goids) == 0 {
+		return 0
+	}
+	var heading Vec3
+	for _, g := range goids {
+		heading = heading.Add(g.vel3().Normalize())
+	}
+	return heading.Len() / float64(len(goids))
+}
+
+// convergence watches a flock's polarization over a sliding window of frames
+// to tell when it's settled down
+type convergence struct {
+	change  float64   // the most polarization can change by over the window and still be settled
+	frames  int       // the size of the window
+	history []float64 // polarization over the last frames, oldest first
+}
+
+// add the latest polarization, and whether it's held within change over
+// the whole window
+func (c *convergence) settled(p float64) bool {
+	c.history = append(c.history, p)
+	if len(c.history) > c.frames {
+		c.history = c.history[1:]
+	}
+	if len(c.history) < c.frames {
+		return false
+	}
+	lo, hi := p, p
+	for _, h := range c.history {
+		lo, hi = math.Min(lo, h), math.Max(hi, h)
+	}
+	return hi-lo < c.change
+}
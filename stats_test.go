@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestNearestDistanceByMetric(t *testing.T) {
+	tests := []struct {
+		metric Metric
+		want   float64
+	}{
+		{Euclidean, 5},
+		{Manhattan, 7},
+		{Chebyshev, 4},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Population = 2
+		cfg.Boundary = Bounce
+		cfg.Metric = tt.metric
+		sim := NewSimulation(cfg)
+		sim.Goids[0].Pos, sim.Goids[1].Pos = Vec2{400, 300}, Vec2{403, 304}
+		if got := sim.Stats().NearestDistance; got != tt.want {
+			t.Errorf("%v: the nearest neighbours are %g apart, want %g", tt.metric, got, tt.want)
+		}
+	}
+}
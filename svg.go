@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+)
+
+// write the flock as it is now to an SVG file, drawn the way draw draws it
+// but as shapes rather than pixels, leaving out the trails and overlays
+func saveSVG(sim *Simulation, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = writeSVG(f, sim); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s: %v", path, err)
+	}
+	return f.Close()
+}
+
+func writeSVG(w io.Writer, sim *Simulation) error {
+	cfg := sim.Config
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		cfg.Width, cfg.Height, cfg.Width, cfg.Height)
+	fmt.Fprintf(b, `<rect width="100%%" height="100%%" %s/>`+"\n", svgFill(cfg.BackgroundColor))
+	for _, o := range cfg.Obstacles {
+		fmt.Fprintf(b, `<circle cx="%.2f" cy="%.2f" r="%.2f" %s/>`+"\n", o.Pos.X, o.Pos.Y, o.Radius, svgFill(obstacleColor))
+	}
+	if c, ok := cfg.window().(circleWindow); ok {
+		fmt.Fprintf(b, `<circle cx="%.2f" cy="%.2f" r="%.2f" fill="none" stroke="%s"/>`+"\n",
+			c.centre.X, c.centre.Y, c.radius, hexColor(windowEdgeColor))
+	}
+	species := cfg.species()
+	for _, goid := range sim.depthOrder() {
+		c := sim.colorOf(goid, species[goid.Species])
+		goid := sim.project(sim.sized(goid, species[goid.Species]))
+		if !goid.Pos.finite() || !goid.Vel.finite() {
+			continue
+		}
+		if cfg.Shape == Triangle {
+			writeSVGTriangle(b, goid, svgFill(c))
+			continue
+		}
+		// a dot with its tail
+		tail := goid.Pos.Sub(goid.Vel.Scale(cfg.TailScale))
+		fmt.Fprintf(b, `<circle cx="%.2f" cy="%.2f" r="%d" %s/>`+"\n", goid.Pos.X, goid.Pos.Y, goid.R, svgFill(c))
+		fmt.Fprintf(b, `<line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" stroke-width="1" %s/>`+"\n",
+			goid.Pos.X, goid.Pos.Y, tail.X, tail.Y, svgStroke(c))
+	}
+	for _, p := range sim.Predators {
+		if p.Pos.finite() && p.Vel.finite() {
+			writeSVGTriangle(b, sim.project(p.Goid), svgFill(p.Color))
+		}
+	}
+	fmt.Fprintln(b, "</svg>")
+	return b.Flush()
+}
+
+func writeSVGTriangle(w io.Writer, g *Goid, fill string) {
+	tip, left, right := trianglePoints(g)
+	fmt.Fprintf(w, `<polygon points="%.2f,%.2f %.2f,%.2f %.2f,%.2f" %s/>`+"\n",
+		tip.X, tip.Y, left.X, left.Y, right.X, right.Y, fill)
+}
+
+// the fill attributes for a color, which has to be un-premultiplied for SVG
+func svgFill(c color.Color) string {
+	rgb, opacity := svgColor(c)
+	return fmt.Sprintf(`fill="%s" fill-opacity="%.3g"`, rgb, opacity)
+}
+
+func svgStroke(c color.Color) string {
+	rgb, opacity := svgColor(c)
+	return fmt.Sprintf(`stroke="%s" stroke-opacity="%.3g"`, rgb, opacity)
+}
+
+func svgColor(c color.Color) (rgb string, opacity float64) {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B), float64(n.A) / 255
+}
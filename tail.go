@@ -0,0 +1,59 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/llgcode/draw2d/draw2dimg"
+)
+
+// tail is where a goid has been drawn over the last few frames
+type tail struct {
+	points []Vec2
+	drawn  int // the last frame it was drawn in, to forget goids that have gone
+}
+
+// add where g's being drawn now to its tail, and return the tail. A goid
+// that's jumped, across the edge in wrap mode, starts a new one.
+func (sim *Simulation) tailOf(g *Goid) []Vec2 {
+	if sim.tails == nil {
+		sim.tails = make(map[int]*tail)
+	}
+	t := sim.tails[g.ID]
+	if t == nil {
+		t = &tail{}
+		sim.tails[g.ID] = t
+	}
+	jump := math.Min(float64(sim.Config.Width), float64(sim.Config.Height)) / 2
+	if n := len(t.points); n > 0 && g.Pos.Sub(t.points[n-1]).Len() > jump {
+		t.points = t.points[:0]
+	}
+	t.points = append(t.points, g.Pos)
+	if extra := len(t.points) - sim.Config.TailSegments - 1; extra > 0 {
+		t.points = t.points[:copy(t.points, t.points[extra:])]
+	}
+	t.drawn = sim.draws
+	return t.points
+}
+
+// forget the tails of goids that weren't drawn this frame
+func (sim *Simulation) pruneTails() {
+	for id, t := range sim.tails {
+		if t.drawn != sim.draws {
+			delete(sim.tails, id)
+		}
+	}
+}
+
+// draw a tail through the points, oldest first, tapering from nothing at the
+// oldest end to width at the goid
+func drawTail(gc *draw2dimg.GraphicContext, points []Vec2, c color.Color, width float64) {
+	gc.SetStrokeColor(c)
+	for i := 1; i < len(points); i++ {
+		gc.SetLineWidth(width * float64(i) / float64(len(points)-1))
+		gc.BeginPath()
+		gc.MoveTo(points[i-1].X, points[i-1].Y)
+		gc.LineTo(points[i].X, points[i].Y)
+		gc.Stroke()
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// ANSI escape sequence codes to perform action on terminal, written to w
+// which is normally os.Stdout
+func hideCursor(w io.Writer) {
+	fmt.Fprint(w, "\033[?25l")
+}
+
+func showCursor(w io.Writer) {
+	fmt.Fprint(w, "\x1b[?25h\n")
+}
+
+func clearScreen(w io.Writer) {
+	fmt.Fprint(w, "\x1b[2J")
+}
+
+// write the status line in place on the top row, which the image starts
+// under, so it never scrolls the terminal or lands on the image. The cursor
+// is put back after, so it's left under the image when the run ends.
+func printStatus(w io.Writer, status string) {
+	fmt.Fprint(w, "\x1b7\x1b[1;1H"+status+"\x1b[K\x1b8")
+}
+
+// this only works for iTerm!
+func printImage(w io.Writer, img image.Image, enc encoder) error {
+	var buf bytes.Buffer
+	if err := enc.encode(&buf, img); err != nil {
+		return fmt.Errorf("encoding frame: %v", err)
+	}
+	imgBase64Str := base64.StdEncoding.EncodeToString(buf.Bytes())
+	_, err := fmt.Fprintf(w, "\x1b[2;0H\x1b]1337;File=inline=1:%s\a", imgBase64Str)
+	return err
+}
+
+// kitty limits each chunk of an image transmission to 4096 bytes of base64
+const kittyChunkSize = 4096
+
+// this only works for Kitty (and terminals that speak its graphics protocol,
+// like WezTerm). The PNG is sent in chunks as APC escape sequences, always as
+// image 1 at placement 1 so each frame replaces the last one. The protocol
+// doesn't take JPEG, so it's always PNG.
+func printImageKitty(w io.Writer, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return fmt.Errorf("encoding frame: %v", err)
+	}
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+	if _, err := fmt.Fprint(w, "\x1b[2;0H"); err != nil {
+		return err
+	}
+	for first := true; first || len(data) > 0; first = false {
+		chunk := data
+		if len(chunk) > kittyChunkSize {
+			chunk = chunk[:kittyChunkSize]
+		}
+		data = data[len(chunk):]
+		more := 0
+		if len(data) > 0 {
+			more = 1
+		}
+		var err error
+		if first {
+			_, err = fmt.Fprintf(w, "\x1b_Ga=T,f=100,i=1,p=1,q=2,m=%d;%s\x1b\\", more, chunk)
+		} else {
+			_, err = fmt.Fprintf(w, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderer displays frames on a particular kind of terminal by writing
+// them to w
+type renderer interface {
+	printImage(w io.Writer, img image.Image) error
+}
+
+type itermRenderer struct{ enc encoder }
+
+func (r itermRenderer) printImage(w io.Writer, img image.Image) error {
+	return printImage(w, img, r.enc)
+}
+
+type kittyRenderer struct{}
+
+func (kittyRenderer) printImage(w io.Writer, img image.Image) error { return printImageKitty(w, img) }
+
+type sixelRenderer struct{}
+
+func (sixelRenderer) printImage(w io.Writer, img image.Image) error { return printImageSixel(w, img) }
+
+// pick the renderer for the named terminal, or guess it from the environment
+// if the name is "auto". Terminals that are sent images as files get them
+// encoded with enc, if they can show that kind. Terminals only shown
+// characters need to know the background, to tell what's drawn on it.
+func newRenderer(terminal string, enc encoder, background color.RGBA) (renderer, error) {
+	if terminal == "auto" {
+		terminal = detectTerminal()
+	}
+	switch terminal {
+	case "iterm":
+		return itermRenderer{enc}, nil
+	case "kitty":
+		return kittyRenderer{}, nil
+	case "sixel":
+		return sixelRenderer{}, nil
+	case "blocks":
+		return blockRenderer{background}, nil
+	case "braille":
+		return textRenderer{braille: true, background: background}, nil
+	case "ascii":
+		return textRenderer{background: background}, nil
+	}
+	return nil, fmt.Errorf("unknown terminal %q, must be one of auto, iterm, kitty, sixel, blocks, braille or ascii", terminal)
+}
+
+// guess which kind of terminal we're running in, falling back to colored
+// blocks for truecolor terminals and braille text if it doesn't look like one
+// that can show images
+func detectTerminal() string {
+	termName, program := os.Getenv("TERM"), os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || strings.Contains(termName, "kitty"):
+		return "kitty"
+	case program == "iTerm.app" || program == "WezTerm" || os.Getenv("LC_TERMINAL") == "iTerm2":
+		return "iterm"
+	case strings.HasPrefix(termName, "foot") || strings.HasPrefix(termName, "mlterm"):
+		return "sixel"
+	case os.Getenv("COLORTERM") == "truecolor" || os.Getenv("COLORTERM") == "24bit":
+		return "blocks"
+	}
+	return "braille"
+}
+
+// size of the terminal in characters, or a classic 80x24 if it can't be told
+func terminalSize() (cols, rows int) {
+	cols, rows, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return 80, 24
+	}
+	return cols, rows
+}
+
+// the size of window that fills the terminal in pixels, leaving room for
+// the status line, or false if the terminal won't say how big it is in
+// pixels, as plenty don't
+func terminalFit() (width, height int, ok bool) {
+	_, rows, width, height, ok := terminalWinsize()
+	// the status line is the top row and the image starts under it, leaving
+	// the bottom row for the cursor so showing it doesn't scroll
+	if !ok || width <= 0 || height <= 0 || rows <= 2 {
+		return 0, 0, false
+	}
+	return width, height * (rows - 2) / rows, true
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+var errBrokenPipe = errors.New("broken pipe")
+
+// a writer that's gone away, like a terminal that's been closed
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errBrokenPipe }
+
+func TestRenderersReturnWriteErrors(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 80, 60))
+	for _, terminal := range []string{"iterm", "kitty", "sixel", "blocks", "braille", "ascii"} {
+		for _, format := range []ImageFormat{PNG, JPEG} {
+			r, err := newRenderer(terminal, encoder{format: format, quality: 90}, color.RGBA{0, 0, 0, 255})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := r.printImage(failingWriter{}, img); !errors.Is(err, errBrokenPipe) {
+				t.Errorf("%s showing a %v frame on a broken terminal returned %v, want %v", terminal, format, err, errBrokenPipe)
+			}
+		}
+	}
+}
+
+func TestEncodeReturnsWriteErrors(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 80, 60))
+	for _, format := range []ImageFormat{PNG, JPEG} {
+		if err := (encoder{format: format, quality: 90}).encode(failingWriter{}, img); !errors.Is(err, errBrokenPipe) {
+			t.Errorf("encoding a %v frame to a broken writer returned %v, want %v", format, err, errBrokenPipe)
+		}
+	}
+}
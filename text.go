@@ -0,0 +1,89 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"io"
+	"strings"
+)
+
+// braille dot bits for each of the 2x4 dots in a braille cell, indexed [y][x]
+var brailleDots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// textRenderer draws frames as characters for terminals that can't show
+// images at all, either as braille dots (2x4 dots per character) or as *
+type textRenderer struct {
+	braille    bool
+	background color.RGBA // pixels this color are left blank
+}
+
+// scale the frame down onto a grid the size of the terminal and print it in
+// place, a character is marked wherever anything but the background lands in
+// it
+func (t textRenderer) printImage(w io.Writer, img image.Image) error {
+	cols, rows := terminalSize()
+	rows -= 2 // leave room for the status line
+	if cols < 1 || rows < 1 {
+		return nil
+	}
+	dw, dh := cols, rows
+	if t.braille {
+		dw, dh = cols*2, rows*4
+	}
+	dots := make([]bool, dw*dh)
+	bounds := img.Bounds()
+	iw, ih := bounds.Dx(), bounds.Dy()
+	for y := 0; y < ih; y++ {
+		for x := 0; x < iw; x++ {
+			if lit(img, bounds.Min.X+x, bounds.Min.Y+y, t.background) {
+				dots[(y*dh/ih)*dw+x*dw/iw] = true
+			}
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\x1b[2;0H")
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			if t.braille {
+				ch := rune(0x2800)
+				for dy := 0; dy < 4; dy++ {
+					for dx := 0; dx < 2; dx++ {
+						if dots[(row*4+dy)*dw+col*2+dx] {
+							ch |= brailleDots[dy][dx]
+						}
+					}
+				}
+				sb.WriteRune(ch)
+			} else if dots[row*dw+col] {
+				sb.WriteByte('*')
+			} else {
+				sb.WriteByte(' ')
+			}
+		}
+		sb.WriteString("\r\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// whether a pixel has anything drawn on it, which is whether it's far enough
+// from the background not to be just a faded trail or a bit of antialiasing
+func lit(img image.Image, x, y int, background color.RGBA) bool {
+	r, g, b, _ := img.At(x, y).RGBA()
+	br, bg, bb, _ := background.RGBA()
+	return diff(r, br)+diff(g, bg)+diff(b, bb) > 0x3000
+}
+
+// how far apart 2 color channels are
+func diff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestLit(t *testing.T) {
+	black := color.RGBA{0, 0, 0, 255}
+	white := color.RGBA{255, 255, 255, 255}
+	goid := color.RGBA{200, 200, 100, 255}
+	tests := []struct {
+		background, pixel color.RGBA
+		want              bool
+	}{
+		{black, black, false},
+		{black, goid, true},
+		{black, color.RGBA{10, 10, 10, 255}, false}, // a trail all but faded away
+		{white, white, false},
+		{white, goid, true},
+		{white, black, true},
+		{white, color.RGBA{250, 250, 250, 255}, false},
+		{color.RGBA{0, 0, 80, 255}, color.RGBA{0, 0, 80, 255}, false},
+	}
+	for _, tt := range tests {
+		img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+		img.SetRGBA(0, 0, tt.pixel)
+		if got := lit(img, 0, 0, tt.background); got != tt.want {
+			t.Errorf("lit(%v on %v) = %v, want %v", tt.pixel, tt.background, got, tt.want)
+		}
+	}
+}
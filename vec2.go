@@ -0,0 +1,43 @@
+package main
+
+import "math"
+
+// Vec2 is a 2D vector used for goid positions and velocities
+type Vec2 struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Add returns v + u
+func (v Vec2) Add(u Vec2) Vec2 {
+	return Vec2{v.X + u.X, v.Y + u.Y}
+}
+
+// Sub returns v - u
+func (v Vec2) Sub(u Vec2) Vec2 {
+	return Vec2{v.X - u.X, v.Y - u.Y}
+}
+
+// Scale returns v multiplied by s
+func (v Vec2) Scale(s float64) Vec2 {
+	return Vec2{v.X * s, v.Y * s}
+}
+
+// Len returns the length (magnitude) of v
+func (v Vec2) Len() float64 {
+	return math.Sqrt(v.X*v.X + v.Y*v.Y)
+}
+
+// Normalize returns a unit vector in the direction of v, or the zero vector if v has no length
+func (v Vec2) Normalize() Vec2 {
+	l := v.Len()
+	if l == 0 {
+		return Vec2{}
+	}
+	return Vec2{v.X / l, v.Y / l}
+}
+
+// finite reports whether neither part of v is NaN or infinite
+func (v Vec2) finite() bool {
+	return !math.IsNaN(v.X) && !math.IsInf(v.X, 0) && !math.IsNaN(v.Y) && !math.IsInf(v.Y, 0)
+}
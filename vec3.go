@@ -0,0 +1,48 @@
+package main
+
+import "math"
+
+// Vec3 is a 3D vector, used to steer goids in 3D mode. In 2D mode Z is
+// always 0 and it works out the same as a Vec2.
+type Vec3 struct {
+	X, Y, Z float64
+}
+
+// Vec3 returns v in the plane Z = 0
+func (v Vec2) Vec3() Vec3 {
+	return Vec3{v.X, v.Y, 0}
+}
+
+// Add returns v + u
+func (v Vec3) Add(u Vec3) Vec3 {
+	return Vec3{v.X + u.X, v.Y + u.Y, v.Z + u.Z}
+}
+
+// Sub returns v - u
+func (v Vec3) Sub(u Vec3) Vec3 {
+	return Vec3{v.X - u.X, v.Y - u.Y, v.Z - u.Z}
+}
+
+// Scale returns v multiplied by s
+func (v Vec3) Scale(s float64) Vec3 {
+	return Vec3{v.X * s, v.Y * s, v.Z * s}
+}
+
+// Dot returns the dot product of v and u
+func (v Vec3) Dot(u Vec3) float64 {
+	return v.X*u.X + v.Y*u.Y + v.Z*u.Z
+}
+
+// Len returns the length (magnitude) of v
+func (v Vec3) Len() float64 {
+	return math.Sqrt(v.Dot(v))
+}
+
+// Normalize returns a unit vector in the direction of v, or the zero vector if v has no length
+func (v Vec3) Normalize() Vec3 {
+	l := v.Len()
+	if l == 0 {
+		return Vec3{}
+	}
+	return v.Scale(1 / l)
+}
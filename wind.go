@@ -0,0 +1,118 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/llgcode/draw2d/draw2dimg"
+)
+
+// how fast the wind changes, in noise units per unit of time
+const windDrift = 0.005
+
+// how far apart the arrows showing the wind are drawn, and how long a full
+// strength one is
+const (
+	windArrowSpacing = 40
+	windArrowLength  = 15
+)
+
+var windArrowColor = color.RGBA{50, 50, 80, 255}
+
+// windField is a wind that blows differently from place to place and slowly
+// changes over time, from 3D Perlin noise over x, y and time. It has its own
+// random source so turning it on doesn't change the rest of the run.
+type windField struct {
+	seed int64
+	perm [512]uint8
+}
+
+func newWindField(seed int64) *windField {
+	w := &windField{seed: seed}
+	p := rand.New(rand.NewSource(seed)).Perm(256)
+	for i := range w.perm {
+		w.perm[i] = uint8(p[i&255])
+	}
+	return w
+}
+
+// the wind for the simulation's seed, made again if the seed's changed
+func (s *Simulation) windField() *windField {
+	if s.wind == nil || s.wind.seed != s.Config.Seed {
+		s.wind = newWindField(s.Config.Seed)
+	}
+	return s.wind
+}
+
+// the push of the wind at p at time t. The noise picks which way it blows,
+// so it swirls round rather than bunching goids up, always at the same
+// strength.
+func (w *windField) at(p Vec2, t, scale, strength float64) Vec2 {
+	angle := 2 * math.Pi * w.noise(p.X/scale, p.Y/scale, t*windDrift)
+	return Vec2{math.Cos(angle), math.Sin(angle)}.Scale(strength)
+}
+
+// improved Perlin noise, roughly between -1 and 1
+func (w *windField) noise(x, y, z float64) float64 {
+	fx, fy, fz := math.Floor(x), math.Floor(y), math.Floor(z)
+	X, Y, Z := int(fx)&255, int(fy)&255, int(fz)&255
+	x, y, z = x-fx, y-fy, z-fz
+	u, v, t := fade(x), fade(y), fade(z)
+	p := &w.perm
+	a, b := int(p[X])+Y, int(p[X+1])+Y
+	aa, ab := int(p[a])+Z, int(p[a+1])+Z
+	ba, bb := int(p[b])+Z, int(p[b+1])+Z
+	return lerp(t,
+		lerp(v,
+			lerp(u, grad(p[aa], x, y, z), grad(p[ba], x-1, y, z)),
+			lerp(u, grad(p[ab], x, y-1, z), grad(p[bb], x-1, y-1, z))),
+		lerp(v,
+			lerp(u, grad(p[aa+1], x, y, z-1), grad(p[ba+1], x-1, y, z-1)),
+			lerp(u, grad(p[ab+1], x, y-1, z-1), grad(p[bb+1], x-1, y-1, z-1))))
+}
+
+func fade(t float64) float64 { return t * t * t * (t*(t*6-15) + 10) }
+
+func lerp(t, a, b float64) float64 { return a + t*(b-a) }
+
+// the dot product of x, y, z with one of 12 gradients picked by hash
+func grad(hash uint8, x, y, z float64) float64 {
+	h := hash & 15
+	u, v := x, y
+	if h >= 8 {
+		u = y
+	}
+	if h >= 4 {
+		v = z
+		if h == 12 || h == 14 {
+			v = x
+		}
+	}
+	if h&1 != 0 {
+		u = -u
+	}
+	if h&2 != 0 {
+		v = -v
+	}
+	return u + v
+}
+
+// draw a faint grid of arrows showing which way the wind's blowing
+func drawWind(gc *draw2dimg.GraphicContext, sim *Simulation) {
+	cfg := sim.Config
+	w := sim.windField()
+	t := float64(sim.Frame) * cfg.TimeStep
+	gc.SetStrokeColor(windArrowColor)
+	gc.SetLineWidth(1)
+	gc.BeginPath()
+	for y := windArrowSpacing / 2; y < cfg.Height; y += windArrowSpacing {
+		for x := windArrowSpacing / 2; x < cfg.Width; x += windArrowSpacing {
+			p := Vec2{float64(x), float64(y)}
+			tip := p.Add(w.at(p, t, cfg.WindScale, windArrowLength))
+			gc.MoveTo(p.X, p.Y)
+			gc.LineTo(tip.X, tip.Y)
+		}
+	}
+	gc.Stroke()
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/llgcode/draw2d/draw2dimg"
+)
+
+// WindowShape is the shape of the space the flock is kept inside
+type WindowShape int
+
+const (
+	RectangleWindow WindowShape = iota // the whole frame
+	CircleWindow                       // the biggest circle that fits in the middle of the frame
+)
+
+var windowShapes = []string{"rectangle", "circle"}
+
+func (w WindowShape) String() string { return enumName(windowShapes, int(w)) }
+
+// MarshalText lets the shape be used as a flag and in JSON by name
+func (w WindowShape) MarshalText() ([]byte, error) { return []byte(w.String()), nil }
+
+// UnmarshalText parses the name of a window shape
+func (w *WindowShape) UnmarshalText(text []byte) error {
+	return parseEnum(windowShapes, text, "window shape", (*int)(w))
+}
+
+// Window is the edge of the space the flock is kept inside, in 2D. In 3D
+// it's the same shape all the way from the front to the back.
+type Window interface {
+	// whether p is inside the window
+	Contains(p Vec2) bool
+	// put a goid that's gone out back inside, bouncing it off the edge or
+	// bringing it back in on the other side, by the boundary mode
+	Resolve(g *Goid, mode BoundaryMode)
+	// how far p is into a margin of the given width inside the edge, as a
+	// fraction of the margin in each direction, pointing away from the edge
+	edgeTurn(p Vec2, margin float64) Vec2
+	// p, a point in the frame, moved to the same place in the window, so a
+	// flock spawned across the frame fills the window
+	fit(p Vec2) Vec2
+	// trace the edge onto gc, unless it's the edge of the frame anyway
+	trace(gc *draw2dimg.GraphicContext)
+}
+
+var windowEdgeColor = color.RGBA{60, 60, 60, 255}
+
+// the window goids are kept inside
+func (c Config) window() Window {
+	w, h := float64(c.Width), float64(c.Height)
+	if c.WindowShape == CircleWindow {
+		return circleWindow{centre: Vec2{w / 2, h / 2}, radius: math.Min(w, h) / 2}
+	}
+	return rectangleWindow{width: w, height: h}
+}
+
+// whether the window is a torus, a rectangle that goids seen across one edge
+// of carry on from the other
+func (c Config) torus() bool {
+	return c.Boundary == Wrap && c.WindowShape == RectangleWindow
+}
+
+type rectangleWindow struct {
+	width, height float64
+}
+
+func (r rectangleWindow) Contains(p Vec2) bool {
+	return p.X >= 0 && p.Y >= 0 && p.X <= r.width && p.Y <= r.height
+}
+
+func (r rectangleWindow) Resolve(g *Goid, mode BoundaryMode) {
+	if mode == Bounce {
+		bounceOffWalls(g, r.width, r.height)
+	} else {
+		stayInWindow(g, r.width, r.height)
+	}
+}
+
+func (r rectangleWindow) edgeTurn(p Vec2, margin float64) Vec2 {
+	return Vec2{edgeTurn(p.X, r.width, margin), edgeTurn(p.Y, r.height, margin)}
+}
+
+func (r rectangleWindow) fit(p Vec2) Vec2 { return p }
+
+func (r rectangleWindow) trace(*draw2dimg.GraphicContext) {}
+
+type circleWindow struct {
+	centre Vec2
+	radius float64
+}
+
+func (c circleWindow) Contains(p Vec2) bool {
+	return p.Sub(c.centre).Len() <= c.radius
+}
+
+// a goid that's gone out either bounces back in off the edge or, wrapping,
+// comes back in on the far side of the circle as far as it went out
+func (c circleWindow) Resolve(g *Goid, mode BoundaryMode) {
+	out := g.Pos.Sub(c.centre)
+	dist := out.Len()
+	if dist <= c.radius {
+		return
+	}
+	normal := out.Scale(1 / dist)
+	if mode == Bounce {
+		g.Pos = c.centre.Add(normal.Scale(c.radius))
+		if heading := g.Vel.X*normal.X + g.Vel.Y*normal.Y; heading > 0 {
+			g.Vel = g.Vel.Sub(normal.Scale(2 * heading))
+		}
+		return
+	}
+	g.Pos = c.centre.Sub(normal.Scale(math.Max(0, 2*c.radius-dist)))
+}
+
+func (c circleWindow) edgeTurn(p Vec2, margin float64) Vec2 {
+	out := p.Sub(c.centre)
+	into := out.Len() - (c.radius - margin)
+	if into <= 0 {
+		return Vec2{}
+	}
+	return out.Normalize().Scale(-into / margin)
+}
+
+// stretch the frame onto the circle along the line from the centre, so the
+// corners of the frame land on the circle
+func (c circleWindow) fit(p Vec2) Vec2 {
+	out := p.Sub(c.centre)
+	if out == (Vec2{}) {
+		return p
+	}
+	// how far the edge of the frame is from the centre that way, the frame
+	// being the square or rectangle around the circle
+	halfW, halfH := c.centre.X, c.centre.Y
+	edge := math.Min(halfW/math.Abs(out.X), halfH/math.Abs(out.Y)) * out.Len()
+	return c.centre.Add(out.Scale(c.radius / edge))
+}
+
+func (c circleWindow) trace(gc *draw2dimg.GraphicContext) {
+	gc.MoveTo(c.centre.X+c.radius, c.centre.Y)
+	gc.ArcTo(c.centre.X, c.centre.Y, c.radius, c.radius, 0, -math.Pi*2)
+	gc.Close()
+}
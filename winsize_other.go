@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// there's no asking the terminal for its size in pixels here
+func terminalWinsize() (cols, rows, width, height int, ok bool) {
+	return 0, 0, 0, 0, false
+}
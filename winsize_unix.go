@@ -0,0 +1,19 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// size of the terminal in characters and in pixels, with the pixels 0 if
+// the terminal doesn't say
+func terminalWinsize() (cols, rows, width, height int, ok bool) {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0, 0, 0, 0, false
+	}
+	return int(ws.Col), int(ws.Row), int(ws.Xpixel), int(ws.Ypixel), true
+}